@@ -0,0 +1,105 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"time"
+)
+
+// requestIDKey is the user value holding the request ID
+const requestIDKey = "limq_request_id"
+
+// channelKey is the user value holding the channel the request is authorized for
+const channelKey = "limq_channel"
+
+const maxRequestIDLength = 128
+
+// validRequestID accepts the client's request IDs made of the URL-safe characters only,
+// so that they can't forge log lines or headers
+func validRequestID(id []byte) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// requestID returns the ID assigned by AccessLogMiddleware, empty outside of it
+func requestID(ctx *fasthttp.RequestCtx) string {
+	id, _ := ctx.UserValue(requestIDKey).(string)
+	return id
+}
+
+// requestLogger is the global logger tagged with the request ID
+func requestLogger(ctx *fasthttp.RequestCtx) *zap.Logger {
+	return zap.L().With(zap.String("request_id", requestID(ctx)))
+}
+
+// loggedChannel returns the channel the request was authorized for, or the one named in the route
+func loggedChannel(ctx *fasthttp.RequestCtx) string {
+	if tag, ok := ctx.UserValue(channelKey).(string); ok {
+		return tag
+	}
+
+	return requestedChannel(ctx)
+}
+
+// AccessLogMiddleware assigns the request ID, or keeps the client's X-Request-ID, and logs the served request.
+// The route is logged instead of the path, so the access keys never make it to the log
+func AccessLogMiddleware(f func(ctx *fasthttp.RequestCtx)) func(ctx *fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+
+		id := newRequestID()
+		if incoming := ctx.Request.Header.Peek("X-Request-ID"); validRequestID(incoming) {
+			id = string(incoming)
+		}
+
+		ctx.SetUserValue(requestIDKey, id)
+		ctx.Response.Header.Set("X-Request-ID", id)
+
+		f(ctx)
+
+		route := routeName(ctx)
+
+		// the probes would flood the log
+		level := zapcore.InfoLevel
+		if route == "/healthz" || route == "/readyz" {
+			level = zapcore.DebugLevel
+		}
+
+		if ce := zap.L().Check(level, "request"); ce != nil {
+			ce.Write(
+				zap.String("request_id", id),
+				zap.ByteString("method", ctx.Method()),
+				zap.String("route", route),
+				zap.String("chan_id", loggedChannel(ctx)),
+				zap.Int("status", ctx.Response.StatusCode()),
+				zap.Int("api_code", resultCode(ctx)),
+				zap.Int("request_size", len(ctx.Request.Body())),
+				zap.Int("response_size", len(ctx.Response.Body())),
+				zap.Duration("latency", time.Since(start)),
+				zap.String("client_ip", ctx.RemoteIP().String()),
+			)
+		}
+	}
+}
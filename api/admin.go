@@ -0,0 +1,672 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"limq/audit"
+	"limq/authenticator"
+	"limq/broker"
+	"limq/listeners"
+	"limq/message"
+	"limq/quota"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const adminTimeout = 5 * time.Second
+
+type channelRequest struct {
+	Keys []authenticator.KeyInfo `json:"keys"`
+}
+
+type forwardsRequest struct {
+	Targets []string `json:"targets"`
+}
+
+type channelResponse struct {
+	hasCode
+	Tag  string                  `json:"channel_id"`
+	Keys []authenticator.KeyInfo `json:"keys"`
+}
+
+type limitsRequest struct {
+	Limits         quota.Limits     `json:"limits"`
+	ListenerPolicy listeners.Policy `json:"listener_policy"`
+}
+
+type keyResponse struct {
+	hasCode
+	authenticator.KeyInfo
+}
+
+type forwardsResponse struct {
+	hasCode
+	Tag     string   `json:"channel_id"`
+	Targets []string `json:"targets"`
+}
+
+type rulesRequest struct {
+	Rules []broker.ForwardRule `json:"rules"`
+}
+
+type rulesResponse struct {
+	hasCode
+	Tag   string               `json:"channel_id"`
+	Rules []broker.ForwardRule `json:"rules"`
+}
+
+// adminMiddleware guards the admin API with a bearer token
+func (stub *Stub) adminMiddleware(f func(ctx *fasthttp.RequestCtx)) func(ctx *fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentTypeBytes(strApplicationJSON)
+
+		header := string(ctx.Request.Header.Peek("Authorization"))
+		token := strings.TrimPrefix(header, "Bearer ")
+
+		if token == header || subtle.ConstantTimeCompare([]byte(token), []byte(stub.adminToken)) != 1 {
+			stub.record(ctx, audit.KindAuthFailed, "", "", "admin token is missing or invalid")
+
+			setError(ctx, http.StatusUnauthorized)
+			writeError(ctx, CodeAuthenticationError, "admin token is missing or invalid")
+
+			return
+		}
+
+		f(ctx)
+	}
+}
+
+func readJSON(ctx *fasthttp.RequestCtx, v any) bool {
+	if len(ctx.PostBody()) == 0 {
+		return true
+	}
+
+	if err := json.Unmarshal(ctx.PostBody(), v); err != nil {
+		setError(ctx, http.StatusBadRequest)
+		writeError(ctx, CodeInvalidArgument, "malformed JSON body: "+err.Error())
+
+		return false
+	}
+
+	return true
+}
+
+func writeAdminError(ctx *fasthttp.RequestCtx, err error) {
+	switch {
+	case errors.Is(err, authenticator.ErrInvalidTag),
+		errors.Is(err, authenticator.ErrInvalidPermissions),
+		errors.Is(err, authenticator.ErrInvalidLimits),
+		errors.Is(err, authenticator.ErrInvalidPolicy),
+		errors.Is(err, authenticator.ErrSelfForward),
+		errors.Is(err, authenticator.ErrForwardCycle),
+		errors.Is(err, broker.ErrInvalidRule):
+		setError(ctx, http.StatusBadRequest)
+		writeError(ctx, CodeInvalidArgument, err.Error())
+
+	case errors.Is(err, authenticator.ErrKeyDeprecated):
+		setError(ctx, http.StatusConflict)
+		writeError(ctx, CodeInvalidArgument, err.Error())
+
+	case errors.Is(err, authenticator.ErrKeyNotFound):
+		setError(ctx, http.StatusNotFound)
+		writeError(ctx, CodeNotFound, err.Error())
+
+	default:
+		requestLogger(ctx).Error("admin request failed", zap.String("route", routeName(ctx)), zap.Error(err))
+
+		setError(ctx, http.StatusInternalServerError)
+		writeError(ctx, CodeUnknownError, "unable to complete the request due to server error")
+	}
+}
+
+func (stub *Stub) adminCreateChannel(ctx *fasthttp.RequestCtx) {
+	req := channelRequest{}
+	if !readJSON(ctx, &req) {
+		return
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	for _, k := range req.Keys {
+		if err := authenticator.ValidateKeySpec(k); err != nil {
+			writeAdminError(ctx, err)
+			return
+		}
+	}
+
+	// the channel is created along with all of its keys or not at all
+	tag, keys, err := stub.keys.CreateChannel(c, req.Keys)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	response := channelResponse{Tag: tag, Keys: keys}
+	if response.Keys == nil {
+		response.Keys = []authenticator.KeyInfo{}
+	}
+
+	for _, info := range keys {
+		stub.record(ctx, audit.KindAdmin, tag, info.Key, "key created")
+	}
+
+	stub.record(ctx, audit.KindAdmin, tag, "", "channel created")
+
+	ctx.SetStatusCode(http.StatusCreated)
+	writeJSON(ctx, response)
+}
+
+func (stub *Stub) adminCreateKey(ctx *fasthttp.RequestCtx) {
+	tag := ctx.UserValue("tag").(string)
+
+	req := authenticator.KeyInfo{}
+	if !readJSON(ctx, &req) {
+		return
+	}
+
+	req.Tag = tag
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	info, err := stub.keys.CreateKey(c, req)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	stub.record(ctx, audit.KindAdmin, tag, info.Key, "key created")
+
+	ctx.SetStatusCode(http.StatusCreated)
+	writeJSON(ctx, keyResponse{KeyInfo: info})
+}
+
+func (stub *Stub) adminListKeys(ctx *fasthttp.RequestCtx) {
+	tag := ctx.UserValue("tag").(string)
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	keys, err := stub.keys.ListKeys(c, tag)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, channelResponse{Tag: tag, Keys: keys})
+}
+
+func (stub *Stub) adminSetSuspended(suspended bool) func(ctx *fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		key := ctx.UserValue("key").(string)
+
+		c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+		defer cancel()
+
+		info, err := stub.keys.SetSuspended(c, key, suspended)
+		if err != nil {
+			writeAdminError(ctx, err)
+			return
+		}
+
+		if suspended {
+			stub.record(ctx, audit.KindKeySuspended, info.Tag, key, "")
+		} else {
+			stub.record(ctx, audit.KindKeyResumed, info.Tag, key, "")
+		}
+
+		writeJSON(ctx, keyResponse{KeyInfo: info})
+	}
+}
+
+// adminSetLimits replaces the rate limits and the listener policy of a key, the ones missing in the body restore the defaults
+func (stub *Stub) adminSetLimits(ctx *fasthttp.RequestCtx) {
+	key := ctx.UserValue("key").(string)
+
+	req := limitsRequest{}
+	if !readJSON(ctx, &req) {
+		return
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	info, err := stub.keys.SetLimits(c, key, req.Limits, req.ListenerPolicy)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	stub.record(ctx, audit.KindAdmin, info.Tag, key, "limits set to "+formatLimits(info))
+
+	writeJSON(ctx, keyResponse{KeyInfo: info})
+}
+
+func formatLimits(info authenticator.KeyInfo) string {
+	l := info.Limits
+
+	return fmt.Sprintf("%g msg/s, %g B/s, %d concurrent, listener policy %q", l.MessagesPerSecond, l.BytesPerSecond, l.Concurrent, info.ListenerPolicy.String())
+}
+
+func (stub *Stub) adminRotateKey(ctx *fasthttp.RequestCtx) {
+	key := ctx.UserValue("key").(string)
+
+	grace := time.Duration(0)
+
+	if ctx.QueryArgs().Has("grace") {
+		seconds, err := ctx.QueryArgs().GetUint("grace")
+		if err != nil || seconds == 0 {
+			setError(ctx, http.StatusBadRequest)
+			writeError(ctx, CodeInvalidArgument, "grace must be a positive number of seconds")
+
+			return
+		}
+
+		grace = time.Duration(seconds) * time.Second
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	info, err := stub.keys.RotateKey(c, key, grace)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	stub.record(ctx, audit.KindAdmin, info.Tag, key, "key rotated, successor "+auditedKey(info.Key))
+
+	ctx.SetStatusCode(http.StatusCreated)
+	writeJSON(ctx, keyResponse{KeyInfo: info})
+}
+
+func (stub *Stub) adminDeleteKey(ctx *fasthttp.RequestCtx) {
+	key := ctx.UserValue("key").(string)
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	info, err := stub.keys.GetKey(c, key)
+	if err == nil {
+		err = stub.keys.DeleteKey(c, key)
+	}
+
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	stub.record(ctx, audit.KindAdmin, info.Tag, key, "key deleted")
+
+	writeJSON(ctx, struct{ hasCode }{})
+}
+
+func (stub *Stub) adminGetForwards(ctx *fasthttp.RequestCtx) {
+	tag := ctx.UserValue("tag").(string)
+
+	if err := authenticator.ValidateTag(tag); err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	targets := stub.keys.GetForwardDestinations(authenticator.Descriptor{Tag: tag})
+	writeJSON(ctx, forwardsResponse{Tag: tag, Targets: targets})
+}
+
+func (stub *Stub) adminSetForwards(ctx *fasthttp.RequestCtx) {
+	tag := ctx.UserValue("tag").(string)
+
+	req := forwardsRequest{}
+	if !readJSON(ctx, &req) {
+		return
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	if err := stub.keys.SetForwardDestinations(c, tag, req.Targets); err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	stub.record(ctx, audit.KindAdmin, tag, "", "forwards set to ["+strings.Join(req.Targets, ",")+"]")
+
+	if req.Targets == nil {
+		req.Targets = []string{}
+	}
+
+	writeJSON(ctx, forwardsResponse{Tag: tag, Targets: req.Targets})
+}
+
+func (stub *Stub) adminGetRules(ctx *fasthttp.RequestCtx) {
+	tag := ctx.UserValue("tag").(string)
+
+	if err := authenticator.ValidateTag(tag); err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	rules, err := stub.keys.GetForwardRules(c, tag)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, rulesResponse{Tag: tag, Rules: rules})
+}
+
+func (stub *Stub) adminSetRules(ctx *fasthttp.RequestCtx) {
+	tag := ctx.UserValue("tag").(string)
+
+	req := rulesRequest{}
+	if !readJSON(ctx, &req) {
+		return
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	if err := stub.keys.SetForwardRules(c, tag, req.Rules); err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	stub.record(ctx, audit.KindAdmin, tag, "", fmt.Sprintf("%d forwarding rules set", len(req.Rules)))
+
+	if req.Rules == nil {
+		req.Rules = []broker.ForwardRule{}
+	}
+
+	writeJSON(ctx, rulesResponse{Tag: tag, Rules: req.Rules})
+}
+
+type ruleStatusResponse struct {
+	hasCode
+	Tag   string                `json:"channel_id"`
+	Rules []broker.ForwardStats `json:"rules"`
+}
+
+func (stub *Stub) adminRuleStatus(ctx *fasthttp.RequestCtx) {
+	tag := ctx.UserValue("tag").(string)
+
+	if err := authenticator.ValidateTag(tag); err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	stats, err := stub.bufferedBroker.ForwardStats(c, tag)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, ruleStatusResponse{Tag: tag, Rules: stats})
+}
+
+type purgeResponse struct {
+	hasCode
+	Tag    string `json:"channel_id"`
+	Purged int64  `json:"purged"`
+}
+
+func (stub *Stub) adminPurge(ctx *fasthttp.RequestCtx) {
+	tag := ctx.UserValue("tag").(string)
+
+	if err := authenticator.ValidateTag(tag); err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	purged, err := stub.bufferedBroker.Purge(c, tag)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	stub.record(ctx, audit.KindPurge, tag, "", fmt.Sprintf("%d buffered messages dropped", purged))
+
+	writeJSON(ctx, purgeResponse{Tag: tag, Purged: purged})
+}
+
+type channelInfo struct {
+	Tag      string `json:"channel_id"`
+	Buffered int64  `json:"buffered"`
+}
+
+type channelsResponse struct {
+	hasCode
+	Channels []channelInfo `json:"channels"`
+}
+
+// adminListChannels lists the channels issued through the admin API and the ones with buffered messages
+func (stub *Stub) adminListChannels(ctx *fasthttp.RequestCtx) {
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	tags, err := stub.keys.ListChannels(c)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	counts, err := stub.bufferedBroker.BufferedCounts(c)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	response := channelsResponse{Channels: make([]channelInfo, 0, len(tags))}
+
+	for _, tag := range tags {
+		response.Channels = append(response.Channels, channelInfo{Tag: tag, Buffered: counts[tag]})
+		delete(counts, tag)
+	}
+
+	for tag, count := range counts {
+		response.Channels = append(response.Channels, channelInfo{Tag: tag, Buffered: count})
+	}
+
+	sort.Slice(response.Channels, func(i, j int) bool {
+		return response.Channels[i].Tag < response.Channels[j].Tag
+	})
+
+	writeJSON(ctx, response)
+}
+
+type backlogRequest struct {
+	Messages []envelope `json:"messages"`
+}
+
+type backlogResponse struct {
+	hasCode
+	Tag      string     `json:"channel_id"`
+	Messages []envelope `json:"messages"`
+}
+
+type importResponse struct {
+	hasCode
+	Tag      string `json:"channel_id"`
+	Imported int    `json:"imported"`
+}
+
+// adminExport returns the buffered messages of the channel without consuming them
+func (stub *Stub) adminExport(ctx *fasthttp.RequestCtx) {
+	tag := ctx.UserValue("tag").(string)
+
+	if err := authenticator.ValidateTag(tag); err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	backlog, err := stub.bufferedBroker.Backlog(c, tag)
+	if err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	response := backlogResponse{Tag: tag, Messages: make([]envelope, 0, len(backlog))}
+
+	for _, m := range backlog {
+		e := newEnvelope(m)
+		e.TraceParent = m.TraceParent
+
+		response.Messages = append(response.Messages, e)
+	}
+
+	writeJSON(ctx, response)
+}
+
+// adminImport appends the exported messages to the channel's buffered ones.
+// The oldest messages are dropped beyond the buffered messages quota
+func (stub *Stub) adminImport(ctx *fasthttp.RequestCtx) {
+	tag := ctx.UserValue("tag").(string)
+
+	if err := authenticator.ValidateTag(tag); err != nil {
+		writeAdminError(ctx, err)
+		return
+	}
+
+	req := backlogRequest{}
+	if !readJSON(ctx, &req) {
+		return
+	}
+
+	messages := make([]*message.Message, 0, len(req.Messages))
+
+	for i, e := range req.Messages {
+		m, err := e.message(tag)
+		if err != nil {
+			setError(ctx, http.StatusBadRequest)
+			writeError(ctx, CodeInvalidArgument, fmt.Sprintf("message %d: %s", i, err))
+
+			return
+		}
+
+		messages = append(messages, m)
+	}
+
+	imported := 0
+
+	for _, m := range messages {
+		if err := stub.bufferedBroker.Buffer(m); err != nil {
+			if errors.Is(err, broker.ErrMessageIsEmpty) || errors.Is(err, broker.ErrMessageIsTooLarge) {
+				setError(ctx, http.StatusBadRequest)
+				writeError(ctx, CodeInvalidArgument, fmt.Sprintf("message %d: %s, %d messages imported", imported, err, imported))
+
+				return
+			}
+
+			writeAdminError(ctx, err)
+			return
+		}
+
+		imported++
+	}
+
+	stub.record(ctx, audit.KindAdmin, tag, "", fmt.Sprintf("%d buffered messages imported", imported))
+
+	writeJSON(ctx, importResponse{Tag: tag, Imported: imported})
+}
+
+type auditResponse struct {
+	hasCode
+	Events []audit.Event `json:"events"`
+}
+
+// parseTime accepts RFC 3339 or unix seconds
+func parseTime(raw string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+
+	return time.Parse(time.RFC3339, raw)
+}
+
+func (stub *Stub) adminAudit(ctx *fasthttp.RequestCtx) {
+	args := ctx.QueryArgs()
+
+	f := audit.Filter{
+		Channel: string(args.Peek("channel")),
+		Kind:    audit.Kind(args.Peek("kind")),
+		Limit:   args.GetUintOrZero("limit"),
+	}
+
+	for name, target := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if !args.Has(name) {
+			continue
+		}
+
+		t, err := parseTime(string(args.Peek(name)))
+		if err != nil {
+			setError(ctx, http.StatusBadRequest)
+			writeError(ctx, CodeInvalidArgument, name+" must be an RFC 3339 time or unix seconds")
+
+			return
+		}
+
+		*target = t
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	events, err := stub.audit.Query(c, f)
+	if err != nil {
+		if errors.Is(err, audit.ErrQueryUnavailable) {
+			setError(ctx, http.StatusNotImplemented)
+			writeError(ctx, CodeUnknownError, err.Error())
+
+			return
+		}
+
+		writeAdminError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, auditResponse{Events: events})
+}
+
+func (stub *Stub) registerAdminRoutes() {
+	r := stub.routes
+	admin := stub.adminMiddleware
+
+	r.GET("/admin/channels", admin(stub.adminListChannels))
+	r.POST("/admin/channels", admin(stub.adminCreateChannel))
+	r.GET("/admin/channels/{tag}/keys", admin(stub.adminListKeys))
+	r.POST("/admin/channels/{tag}/keys", admin(stub.adminCreateKey))
+	r.GET("/admin/channels/{tag}/forwards", admin(stub.adminGetForwards))
+	r.PUT("/admin/channels/{tag}/forwards", admin(stub.adminSetForwards))
+	r.GET("/admin/channels/{tag}/rules", admin(stub.adminGetRules))
+	r.PUT("/admin/channels/{tag}/rules", admin(stub.adminSetRules))
+	r.GET("/admin/channels/{tag}/rules/status", admin(stub.adminRuleStatus))
+	r.POST("/admin/keys/{key}/suspend", admin(stub.adminSetSuspended(true)))
+	r.POST("/admin/keys/{key}/resume", admin(stub.adminSetSuspended(false)))
+	r.POST("/admin/keys/{key}/rotate", admin(stub.adminRotateKey))
+	r.PATCH("/admin/keys/{key}", admin(stub.adminSetLimits))
+	r.DELETE("/admin/keys/{key}", admin(stub.adminDeleteKey))
+	r.GET("/admin/channels/{tag}/messages", admin(stub.adminExport))
+	r.POST("/admin/channels/{tag}/messages", admin(stub.adminImport))
+	r.DELETE("/admin/channels/{tag}/messages", admin(stub.adminPurge))
+	r.GET("/admin/audit", admin(stub.adminAudit))
+}
@@ -0,0 +1,318 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"limq/authenticator"
+	"limq/broker"
+	"limq/listeners"
+	"limq/quota"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAdminToken = "admin-secret"
+
+var errStoreDown = errors.New("store is down")
+
+// fakeKeys is an in-memory KeyStore serving its keys as an Authenticator
+type fakeKeys struct {
+	authenticator.Authenticator
+
+	mu       *sync.Mutex
+	keys     map[string]authenticator.KeyInfo
+	channels []string
+
+	// fail is returned by the writes if set
+	fail error
+}
+
+func newFakeKeys() *fakeKeys {
+	static, _ := authenticator.NewStatic(authenticator.StaticConfig{})
+	return &fakeKeys{Authenticator: static, mu: &sync.Mutex{}, keys: map[string]authenticator.KeyInfo{}}
+}
+
+func (f *fakeKeys) CheckAccessKey(key string) authenticator.Descriptor {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, ok := f.keys[key]
+	if !ok {
+		return authenticator.Descriptor{}
+	}
+
+	d := authenticator.Descriptor{Tag: info.Tag, Flags: info.Permissions, Origins: info.Origins, Extra: info.Grants}
+	if info.DeprecatedUntil > 0 {
+		d.DeprecatedUntil = time.Unix(info.DeprecatedUntil, 0)
+	}
+
+	return d
+}
+
+func (f *fakeKeys) CreateChannel(_ context.Context, specs []authenticator.KeyInfo) (string, []authenticator.KeyInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fail != nil {
+		return "", nil, f.fail
+	}
+
+	tag := fmt.Sprintf("%016d", len(f.channels)+1)
+	f.channels = append(f.channels, tag)
+
+	keys := make([]authenticator.KeyInfo, len(specs))
+	for i, spec := range specs {
+		spec.Tag = tag
+		spec.Key = fmt.Sprintf("%s-key-%d", tag, i)
+
+		f.keys[spec.Key] = spec
+		keys[i] = spec
+	}
+
+	return tag, keys, nil
+}
+
+func (f *fakeKeys) ListChannels(context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.channels...), nil
+}
+
+func (f *fakeKeys) CreateKey(_ context.Context, spec authenticator.KeyInfo) (authenticator.KeyInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fail != nil {
+		return authenticator.KeyInfo{}, f.fail
+	}
+
+	spec.Key = fmt.Sprintf("%s-key-%d", spec.Tag, len(f.keys))
+	f.keys[spec.Key] = spec
+
+	return spec, nil
+}
+
+func (f *fakeKeys) GetKey(_ context.Context, key string) (authenticator.KeyInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, ok := f.keys[key]
+	if !ok {
+		return authenticator.KeyInfo{}, authenticator.ErrKeyNotFound
+	}
+
+	return info, nil
+}
+
+func (f *fakeKeys) ListKeys(_ context.Context, tag string) ([]authenticator.KeyInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var keys []authenticator.KeyInfo
+	for _, info := range f.keys {
+		if info.Tag == tag {
+			keys = append(keys, info)
+		}
+	}
+
+	return keys, nil
+}
+
+func (f *fakeKeys) update(key string, change func(info *authenticator.KeyInfo)) (authenticator.KeyInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fail != nil {
+		return authenticator.KeyInfo{}, f.fail
+	}
+
+	info, ok := f.keys[key]
+	if !ok {
+		return authenticator.KeyInfo{}, authenticator.ErrKeyNotFound
+	}
+
+	change(&info)
+	f.keys[key] = info
+
+	return info, nil
+}
+
+func (f *fakeKeys) SetSuspended(_ context.Context, key string, suspended bool) (authenticator.KeyInfo, error) {
+	return f.update(key, func(info *authenticator.KeyInfo) {
+		if suspended {
+			info.Permissions |= authenticator.AccessSuspended
+		} else {
+			info.Permissions &^= authenticator.AccessSuspended
+		}
+	})
+}
+
+func (f *fakeKeys) SetLimits(_ context.Context, key string, limits quota.Limits, policy listeners.Policy) (authenticator.KeyInfo, error) {
+	return f.update(key, func(info *authenticator.KeyInfo) {
+		info.Limits = limits
+		info.ListenerPolicy = policy
+	})
+}
+
+func (f *fakeKeys) DeleteKey(_ context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.keys, key)
+	return nil
+}
+
+func (f *fakeKeys) RotateKey(_ context.Context, key string, grace time.Duration) (authenticator.KeyInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, ok := f.keys[key]
+	if !ok {
+		return authenticator.KeyInfo{}, authenticator.ErrKeyNotFound
+	}
+
+	if info.DeprecatedUntil > 0 {
+		return authenticator.KeyInfo{}, authenticator.ErrKeyDeprecated
+	}
+
+	successor := info
+	successor.Key = key + "-next"
+	f.keys[successor.Key] = successor
+
+	info.DeprecatedUntil = time.Now().Add(grace).Truncate(time.Second).Unix()
+	f.keys[key] = info
+
+	return successor, nil
+}
+
+func (f *fakeKeys) GetForwardDestinations(authenticator.Descriptor) []string {
+	return nil
+}
+
+func (f *fakeKeys) SetForwardDestinations(context.Context, string, []string) error {
+	return nil
+}
+
+func (f *fakeKeys) GetForwardRules(context.Context, string) ([]broker.ForwardRule, error) {
+	return nil, nil
+}
+
+func (f *fakeKeys) SetForwardRules(context.Context, string, []broker.ForwardRule) error {
+	return nil
+}
+
+func newAdminStub(keys *fakeKeys) *Stub {
+	return NewStub(keys, Options{AdminToken: testAdminToken, KeyStore: keys})
+}
+
+func adminHeaders() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + testAdminToken}
+}
+
+func TestAdminCreateChannel(t *testing.T) {
+	keys := newFakeKeys()
+	stub := newAdminStub(keys)
+
+	resp := serve(stub, http.MethodPost, "/admin/channels", adminHeaders(), `{"keys": [{"permissions": 3}, {"permissions": 1}]}`)
+	if resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("unexpected status %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	created := channelResponse{}
+	if err := json.Unmarshal(resp.Body(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(created.Keys) != 2 || keys.CheckAccessKey(created.Keys[1].Key).Tag != created.Tag {
+		t.Errorf("unexpected channel %+v", created)
+	}
+}
+
+func TestAdminCreateChannelFailure(t *testing.T) {
+	keys := newFakeKeys()
+	stub := newAdminStub(keys)
+
+	// the second key is invalid, the channel must not be created with the first one only
+	resp := serve(stub, http.MethodPost, "/admin/channels", adminHeaders(), `{"keys": [{"permissions": 3}, {"permissions": 1024}]}`)
+	if resp.StatusCode() != http.StatusBadRequest {
+		t.Errorf("unexpected status %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	keys.fail = errStoreDown
+
+	resp = serve(stub, http.MethodPost, "/admin/channels", adminHeaders(), `{"keys": [{"permissions": 3}]}`)
+	if resp.StatusCode() != http.StatusInternalServerError {
+		t.Errorf("unexpected status %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	if len(keys.channels) != 0 || len(keys.keys) != 0 {
+		t.Errorf("a failed request must create nothing, got %v and %d keys", keys.channels, len(keys.keys))
+	}
+}
+
+func TestAdminAuthentication(t *testing.T) {
+	stub := newAdminStub(newFakeKeys())
+
+	cases := []map[string]string{
+		nil,
+		{"Authorization": "Bearer wrong"},
+		{"Authorization": testAdminToken},
+		{"Authorization": "Basic " + testAdminToken},
+	}
+
+	for _, headers := range cases {
+		resp := serve(stub, http.MethodGet, "/admin/channels", headers, "")
+		if resp.StatusCode() != http.StatusUnauthorized {
+			t.Errorf("%v: unexpected status %d %s", headers, resp.StatusCode(), resp.Body())
+		}
+	}
+
+	if resp := serve(stub, http.MethodGet, "/admin/channels", adminHeaders(), ""); resp.StatusCode() != http.StatusOK {
+		t.Errorf("unexpected status %d %s", resp.StatusCode(), resp.Body())
+	}
+}
+
+func TestAdminRotateKey(t *testing.T) {
+	keys := newFakeKeys()
+	stub := newAdminStub(keys)
+
+	old, _ := keys.CreateKey(context.Background(), authenticator.KeyInfo{Tag: testTag, Permissions: authenticator.AccessWrite})
+
+	resp := serve(stub, http.MethodPost, "/admin/keys/"+old.Key+"/rotate?grace=1", adminHeaders(), "")
+	if resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("unexpected status %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	successor := keyResponse{}
+	if err := json.Unmarshal(resp.Body(), &successor); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := serve(stub, http.MethodPost, "/admin/keys/"+old.Key+"/rotate", adminHeaders(), ""); resp.StatusCode() != http.StatusConflict {
+		t.Errorf("a rotated key must not be rotated again, got %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	until, _ := keys.GetKey(context.Background(), old.Key)
+
+	// the old key keeps working through the grace period, warning its clients
+	resp = serve(stub, http.MethodPost, "/publish"+old.Key, nil, "hello")
+	if resp.StatusCode() != http.StatusOK || len(resp.Header.Peek("Sunset")) == 0 || len(resp.Header.Peek("Deprecation")) == 0 {
+		t.Errorf("unexpected response of the rotated key %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	time.Sleep(time.Until(time.Unix(until.DeprecatedUntil, 0)))
+
+	if resp := serve(stub, http.MethodPost, "/publish"+old.Key, nil, "hello"); resp.StatusCode() != http.StatusUnauthorized {
+		t.Errorf("the rotated key must stop working at deprecated_until, got %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	resp = serve(stub, http.MethodPost, "/publish"+successor.Key, nil, "hello")
+	if resp.StatusCode() != http.StatusOK || len(resp.Header.Peek("Sunset")) > 0 {
+		t.Errorf("unexpected response of the successor %d %s", resp.StatusCode(), resp.Body())
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+	"limq/audit"
+)
+
+// routeName is the matched route pattern, which unlike the path doesn't disclose the access key
+func routeName(ctx *fasthttp.RequestCtx) string {
+	name, _ := ctx.UserValue(router.MatchedRoutePathParam).(string)
+	return name
+}
+
+// auditedKey identifies the access key in the audit log by its keyID, empty if there is no key
+func auditedKey(key string) string {
+	if len(key) == 0 {
+		return ""
+	}
+
+	return keyID(key)
+}
+
+func (stub *Stub) record(ctx *fasthttp.RequestCtx, kind audit.Kind, tag, key, detail string) {
+	stub.audit.Record(audit.Event{
+		Kind:      kind,
+		Channel:   tag,
+		KeyPrefix: auditedKey(key),
+		ClientIP:  ctx.RemoteIP().String(),
+		Action:    string(ctx.Method()) + " " + routeName(ctx),
+		Detail:    detail,
+	})
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuditedKey(t *testing.T) {
+	first, second := auditedKey("t.eyJ0YWciOiJhIn0.c2ln"), auditedKey("t.eyJ0YWciOiJiIn0.c2ln")
+
+	if first == second {
+		t.Error("the tokens must be told apart in the audit log")
+	}
+
+	for _, key := range []string{"abc", "t.eyJ0YWciOiJhIn0.c2ln", testKey} {
+		if id := auditedKey(key); len(id) == 0 || strings.Contains(id, key) {
+			t.Errorf("%q must not be disclosed, got %q", key, id)
+		}
+	}
+
+	if auditedKey("") != "" {
+		t.Error("a missing key must stay empty")
+	}
+}
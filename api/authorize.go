@@ -0,0 +1,109 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/valyala/fasthttp"
+	"limq/audit"
+	"limq/authenticator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// requestedChannel returns the channel named in the route, empty for the key's primary channel
+func requestedChannel(ctx *fasthttp.RequestCtx) string {
+	tag, _ := ctx.UserValue("tag").(string)
+	return tag
+}
+
+// authenticate resolves the access key and checks the request origin against the key's channel.
+// On failure the error response is already written
+func (stub *Stub) authenticate(ctx *fasthttp.RequestCtx, key string) (authenticator.Descriptor, bool) {
+	auth := stub.checkAccessKey(ctx, key)
+	if !auth.Flags.Active() || len(auth.Grants()) == 0 || auth.Expired(time.Now()) {
+		stub.record(ctx, audit.KindAuthFailed, auth.Tag, key, "access key is suspended or invalid")
+
+		setError(ctx, http.StatusUnauthorized)
+		writeError(ctx, CodeAuthenticationError, "access key is suspended or invalid")
+
+		return authenticator.Descriptor{}, false
+	}
+
+	if !stub.originAllowed(ctx, auth) {
+		stub.record(ctx, audit.KindPermissionDenied, auth.Tag, key, "origin is not allowed: "+string(ctx.Request.Header.Peek("Origin")))
+		return authenticator.Descriptor{}, false
+	}
+
+	if auth.Deprecated() {
+		setDeprecationHeaders(ctx, auth.DeprecatedUntil)
+	}
+
+	return auth, true
+}
+
+// authorizeChannel narrows the authenticated key down to the tag channel (the primary one if empty)
+// and checks the permission. On failure the error response is already written
+func (stub *Stub) authorizeChannel(ctx *fasthttp.RequestCtx, key string, auth authenticator.Descriptor, tag string,
+	permitted func(authenticator.AccessLevel) bool, denial string) (authenticator.Descriptor, bool) {
+
+	channel, ok := auth.Channel(tag)
+	if !ok {
+		stub.record(ctx, audit.KindPermissionDenied, tag, key, "channel is not granted")
+
+		setError(ctx, http.StatusForbidden)
+		writeError(ctx, CodeAuthenticationError, "access key is not granted access to the channel")
+
+		return authenticator.Descriptor{}, false
+	}
+
+	if !permitted(channel.Flags) {
+		stub.record(ctx, audit.KindPermissionDenied, channel.Tag, key, denial)
+
+		setError(ctx, http.StatusForbidden)
+		writeError(ctx, CodeAuthenticationError, denial)
+
+		return authenticator.Descriptor{}, false
+	}
+
+	ctx.SetUserValue(channelKey, channel.Tag)
+
+	return channel, true
+}
+
+// authorize authenticates the access key for the channel requested by the route
+func (stub *Stub) authorize(ctx *fasthttp.RequestCtx, key string,
+	permitted func(authenticator.AccessLevel) bool, denial string) (authenticator.Descriptor, bool) {
+
+	auth, ok := stub.authenticate(ctx, key)
+	if !ok {
+		return authenticator.Descriptor{}, false
+	}
+
+	return stub.authorizeChannel(ctx, key, auth, requestedChannel(ctx), permitted, denial)
+}
+
+// setDeprecationHeaders warns the clients of a key being rotated out
+func setDeprecationHeaders(ctx *fasthttp.RequestCtx, until time.Time) {
+	sunset := until.UTC().Format(http.TimeFormat)
+
+	ctx.Response.Header.Set("Deprecation", "@"+strconv.FormatInt(until.Unix(), 10))
+	ctx.Response.Header.Set("Sunset", sunset)
+	ctx.Response.Header.Set("Warning", `299 limq "access key is rotated and stops working at `+sunset+`"`)
+}
+
+// keyID identifies the access key in the shared state without disclosing it
+func keyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ownerID identifies the access key whose limits the request is counted against:
+// the key itself, or the one the token was minted from
+func ownerID(key string, auth authenticator.Descriptor) string {
+	if len(auth.Owner) > 0 {
+		return auth.Owner
+	}
+
+	return keyID(key)
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"github.com/valyala/fasthttp"
+	"limq/audit"
+	"limq/authenticator"
+	"net/http"
+)
+
+// clientCertValue holds the descriptor of an mTLS client, see clientCert
+const clientCertValue = "client_cert"
+
+// certKeyPrefix marks the mTLS client identities standing for access keys in the rate limits,
+// the listener leases and the audit log
+const certKeyPrefix = "x509:"
+
+// clientCert authenticates a request of the key-less routes by the verified client certificate.
+// The certificate is identified through the stub's CertMap
+func (stub *Stub) clientCert(f func(ctx *fasthttp.RequestCtx)) func(ctx *fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		state := ctx.TLSConnectionState()
+		if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+			stub.record(ctx, audit.KindAuthFailed, "", "", "client certificate is missing")
+
+			setError(ctx, http.StatusUnauthorized)
+			writeError(ctx, CodeAuthenticationError, "client certificate is missing or not verified")
+
+			return
+		}
+
+		identity, d := stub.certs.Identify(state.VerifiedChains[0][0])
+
+		ctx.SetUserValue("access_key", certKeyPrefix+identity)
+		ctx.SetUserValue(clientCertValue, d)
+
+		f(ctx)
+	}
+}
+
+// checkAccessKey resolves the access key, or takes the descriptor of the mTLS client
+func (stub *Stub) checkAccessKey(ctx *fasthttp.RequestCtx, key string) authenticator.Descriptor {
+	if d, ok := ctx.UserValue(clientCertValue).(authenticator.Descriptor); ok {
+		return d
+	}
+
+	return stub.auth.CheckAccessKey(key)
+}
+
+// registerCertRoutes serves the API without access keys to the mTLS clients
+func (stub *Stub) registerCertRoutes(cors func(f func(ctx *fasthttp.RequestCtx)) func(ctx *fasthttp.RequestCtx)) {
+	r := stub.routes
+
+	listen := countListens("poll", stub.clientCert(stub.listen))
+	publish := countPublishes(stub.clientCert(stub.publish))
+	subscribe := countListens("ws", stub.clientCert(stub.listenWS))
+	token := stub.clientCert(stub.token)
+	request := countPublishes(stub.clientCert(stub.request))
+	reply := stub.clientCert(stub.reply)
+
+	r.GET("/listen", cors(listen))
+	r.POST("/publish", cors(publish))
+	r.GET("/subscribe", cors(subscribe))
+	r.POST("/token", cors(token))
+	r.POST("/request", cors(request))
+	r.POST(replyRoute, cors(reply))
+
+	r.GET("/channel/{tag}/listen", cors(listen))
+	r.POST("/channel/{tag}/publish", cors(publish))
+	r.GET("/channel/{tag}/subscribe", cors(subscribe))
+	r.POST("/channel/{tag}/token", cors(token))
+	r.POST("/channel/{tag}/request", cors(request))
+}
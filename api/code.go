@@ -10,6 +10,12 @@ const (
 	CodeUnknownMessageType
 	CodeMessageIsEmpty
 	CodeMessageIsTooBig
+	CodeInvalidArgument
+	CodeOriginNotAllowed
+	CodeNotFound
+	CodeRateLimited
+	CodeShuttingDown
+	CodePublishInProgress
 )
 
 type hasCode struct {
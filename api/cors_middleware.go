@@ -2,13 +2,103 @@ package api
 
 import (
 	"github.com/valyala/fasthttp"
+	"strings"
 )
 
-func CorsMiddlewareAny(f func(ctx *fasthttp.RequestCtx)) func(ctx *fasthttp.RequestCtx) {
+const (
+	corsAllowHeaders  = "X-Message-Type, X-Timeout, X-Scope, X-Request-ID, X-Idempotency-Key, X-Correlation-ID, traceparent"
+	corsExposeHeaders = "X-Message-Type, X-Message-Scope, X-Origin-Channel, X-Forward-Hops, X-Forward-Path, traceparent, Deprecation, Sunset, Warning, Retry-After, X-Request-ID, X-Idempotent-Replay, X-Correlation-ID, X-Reply-To"
+	corsAllowMethods  = "OPTIONS, GET, POST"
+)
+
+// CorsPolicy describes which browser origins are allowed to use the API.
+// An origin of "*" matches any origin
+type CorsPolicy struct {
+	AllowedOrigins   []string
+	AllowCredentials bool
+}
+
+var anyOrigin = &CorsPolicy{AllowedOrigins: []string{"*"}}
+
+// ParseOrigins splits a comma-separated origins list
+func ParseOrigins(raw string) []string {
+	parts := strings.Split(raw, ",")
+	origins := make([]string, 0, len(parts))
+
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if len(p) > 0 {
+			origins = append(origins, strings.TrimSuffix(p, "/"))
+		}
+	}
+
+	return origins
+}
+
+func originListed(list []string, origin string) bool {
+	for _, allowed := range list {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// originNamed reports whether the origin is listed by name rather than matched by "*"
+func originNamed(list []string, origin string) bool {
+	for _, allowed := range list {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *CorsPolicy) wildcard() bool {
+	return !p.AllowCredentials && len(p.AllowedOrigins) == 1 && p.AllowedOrigins[0] == "*"
+}
+
+// Allows reports whether the origin may talk to the API.
+// Requests without an Origin header are not cross-origin browser requests and are always allowed
+func (p *CorsPolicy) Allows(origin string) bool {
+	return len(origin) == 0 || originListed(p.AllowedOrigins, origin)
+}
+
+func (p *CorsPolicy) apply(ctx *fasthttp.RequestCtx) {
+	ctx.Response.Header.Set("Access-Control-Allow-Headers", allowedRequestHeaders(ctx))
+	ctx.Response.Header.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+	ctx.Response.Header.Set("Access-Control-Allow-Methods", corsAllowMethods)
+
+	if p.wildcard() {
+		ctx.Response.Header.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	ctx.Response.Header.Add("Vary", "Origin")
+
+	origin := string(ctx.Request.Header.Peek("Origin"))
+	if len(origin) == 0 || !p.Allows(origin) {
+		return
+	}
+
+	ctx.Response.Header.Set("Access-Control-Allow-Origin", origin)
+
+	// credentials are never shared with an origin matched by "*", which would be any site
+	if p.AllowCredentials && originNamed(p.AllowedOrigins, origin) {
+		ctx.Response.Header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func CorsMiddleware(p *CorsPolicy, f func(ctx *fasthttp.RequestCtx)) func(ctx *fasthttp.RequestCtx) {
 	return func(ctx *fasthttp.RequestCtx) {
-		ctx.Response.Header.Set("access-control-allow-origin", "*")
-		ctx.Response.Header.Set("Access-Control-Allow-Headers", "X-Message-Type, X-Timeout")
+		p.apply(ctx)
 
 		f(ctx)
 	}
 }
+
+func CorsMiddlewareAny(f func(ctx *fasthttp.RequestCtx)) func(ctx *fasthttp.RequestCtx) {
+	return CorsMiddleware(anyOrigin, f)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"github.com/valyala/fasthttp"
+	"limq/message"
+	"net/http"
+)
+
+const reasonShuttingDown = "server is shutting down"
+
+// Drain prepares the replica to stop: /readyz reports not ready, new listeners are refused,
+// the open ones are let go and the undelivered messages are buffered in Postgres.
+// It returns once done or when ctx is
+func (stub *Stub) Drain(ctx context.Context) {
+	stub.BeginShutdown()
+	stub.bufferedBroker.BeginDrain()
+
+	done := make(chan struct{})
+
+	go func() {
+		stub.subscriptions.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	stub.bufferedBroker.Drain(ctx)
+}
+
+func (stub *Stub) draining() bool {
+	select {
+	case <-stub.bufferedBroker.Draining():
+		return true
+
+	default:
+		return false
+	}
+}
+
+// rejectDraining refuses a new listener once the drain has begun
+func (stub *Stub) rejectDraining(ctx *fasthttp.RequestCtx) bool {
+	if !stub.draining() {
+		return false
+	}
+
+	ctx.Response.Header.Set("Retry-After", "1")
+
+	setError(ctx, http.StatusServiceUnavailable)
+	writeError(ctx, CodeShuttingDown, reasonShuttingDown)
+
+	return true
+}
+
+// requeueOnDrain keeps a message not delivered by a listener leaving on drain for the other replicas
+func (stub *Stub) requeueOnDrain(m *message.Message) {
+	if m != nil && stub.draining() {
+		stub.bufferedBroker.Requeue(m)
+	}
+}
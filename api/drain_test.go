@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"limq/authenticator"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDrainRequeuesUndelivered(t *testing.T) {
+	stub := newTestStub(t, []authenticator.StaticKey{{
+		Key:         testKey,
+		Tag:         testTag,
+		Permissions: authenticator.AccessRead | authenticator.AccessWrite,
+	}}, Options{})
+
+	delivered := make(chan string, 1)
+
+	go func() {
+		resp := serve(stub, http.MethodGet, "/listen"+testKey, map[string]string{"X-Timeout": "5"}, "")
+		delivered <- string(resp.Body())
+	}()
+
+	// let the listener come online, the messages published meanwhile are streamed to it
+	time.Sleep(100 * time.Millisecond)
+
+	for _, payload := range []string{"1", "2", "3"} {
+		resp := serve(stub, http.MethodPost, "/publish"+testKey, map[string]string{"X-Scope": "one"}, payload)
+		if resp.StatusCode() != http.StatusOK {
+			t.Fatalf("unexpected status %d %s", resp.StatusCode(), resp.Body())
+		}
+	}
+
+	if got := <-delivered; got != "1" {
+		t.Fatalf("unexpected delivery %q", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stub.Drain(ctx)
+
+	backlog, err := stub.bufferedBroker.Backlog(ctx, testTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backlog) != 2 || string(backlog[0].Payload) != "2" || string(backlog[1].Payload) != "3" {
+		t.Errorf("the undelivered messages must be kept, got %d", len(backlog))
+	}
+
+	if resp := serve(stub, http.MethodGet, "/listen"+testKey, nil, ""); resp.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("a drained replica must refuse the listeners, got %d", resp.StatusCode())
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"limq/message"
+)
+
+// envelope wraps a message delivered over a multiplexed subscription.
+// Text payloads are delivered as is in Text, binary ones are base64-encoded in Data
+type envelope struct {
+	Channel string `json:"channel_id"`
+	Type    string `json:"type"`
+	Scope   string `json:"scope"`
+
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// provenance of a forwarded message
+	Origin string   `json:"origin,omitempty"`
+	Hops   int      `json:"hops,omitempty"`
+	Path   []string `json:"path,omitempty"`
+
+	TraceParent string `json:"traceparent,omitempty"`
+
+	// a request awaiting a reply
+	CorrelationID string `json:"correlation_id,omitempty"`
+	ReplyTo       string `json:"reply_to,omitempty"`
+
+	Text *string `json:"text,omitempty"`
+	Data []byte  `json:"data,omitempty"`
+}
+
+func newEnvelope(m *message.Message) envelope {
+	e := envelope{Channel: m.ChannelID, Type: m.Type.String(), Scope: m.Scope.String(), Headers: m.Headers}
+
+	if m.Hops() > 0 {
+		e.Origin, e.Hops, e.Path = m.Origin(), m.Hops(), m.Path
+	}
+
+	if len(m.CorrelationID) > 0 {
+		e.CorrelationID, e.ReplyTo = m.CorrelationID, replyRoute
+	}
+
+	if m.Type == message.TypeText {
+		text := string(m.Payload)
+		e.Text = &text
+	} else {
+		e.Data = m.Payload
+	}
+
+	return e
+}
+
+// message restores a buffered message of the tag channel from an exported envelope
+func (e envelope) message(tag string) (*message.Message, error) {
+	t, ok := message.ParseType(e.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown message type %q", e.Type)
+	}
+
+	m := &message.Message{
+		Type:        t,
+		Scope:       message.ScopeNotifyOne,
+		ChannelID:   tag,
+		Payload:     e.Data,
+		Headers:     e.Headers,
+		Path:        e.Path,
+		TraceParent: e.TraceParent,
+
+		CorrelationID: e.CorrelationID,
+	}
+
+	if e.Text != nil {
+		m.Payload = []byte(*e.Text)
+	}
+
+	return m, nil
+}
+
+func (e envelope) encode() ([]byte, error) {
+	return json.Marshal(e)
+}
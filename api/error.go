@@ -8,6 +8,9 @@ import (
 type statusCodeWithText struct {
 	hasCode
 	hasStatusText
+
+	// RequestID lets the client refer to the failed request, see AccessLogMiddleware
+	RequestID string `json:"request_id,omitempty"`
 }
 
 func writeError(w io.Writer, statusCode int, reason string) {
@@ -15,6 +18,10 @@ func writeError(w io.Writer, statusCode int, reason string) {
 	m.Code = statusCode
 	m.StatusText = reason
 
+	if ctx, ok := w.(*fasthttp.RequestCtx); ok {
+		m.RequestID = requestID(ctx)
+	}
+
 	writeJSON(w, m)
 }
 
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck probes a dependency for /readyz
+type ReadinessCheck struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+type checkResult struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Latency int64  `json:"latency_ms"`
+}
+
+type readinessResponse struct {
+	hasCode
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+// BeginShutdown makes /readyz report not ready, so the orchestrator stops routing new clients
+func (stub *Stub) BeginShutdown() {
+	atomic.StoreUint32(&stub.shuttingDown, 1)
+}
+
+// healthz reports the process is alive
+func (stub *Stub) healthz(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentTypeBytes(strApplicationJSON)
+	writeJSON(ctx, struct {
+		hasCode
+		Status string `json:"status"`
+	}{Status: "ok"})
+}
+
+// readyz probes the dependencies concurrently and reports each of them
+func (stub *Stub) readyz(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentTypeBytes(strApplicationJSON)
+
+	c, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+
+	response := readinessResponse{Status: "ready", Checks: make(map[string]checkResult, len(stub.readiness))}
+
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+
+	for _, check := range stub.readiness {
+		wg.Add(1)
+
+		go func(check ReadinessCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check.Probe(c)
+
+			result := checkResult{Status: "ok", Latency: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = "error"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			response.Checks[check.Name] = result
+		}(check)
+	}
+
+	wg.Wait()
+
+	for _, result := range response.Checks {
+		if result.Status != "ok" {
+			response.Status = "not_ready"
+		}
+	}
+
+	if atomic.LoadUint32(&stub.shuttingDown) == 1 {
+		response.Status = "shutting_down"
+	}
+
+	if response.Status != "ready" {
+		response.Code = CodeUnknownError
+		ctx.SetStatusCode(http.StatusServiceUnavailable)
+	}
+
+	writeJSON(ctx, response)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"limq/idempotency"
+	"net/http"
+	"time"
+)
+
+const idempotencyTimeout = 500 * time.Millisecond
+
+// claimPublish deduplicates a publish to the tag channel by its X-Idempotency-Key.
+// The returned key must be passed to finishPublish, it's empty if the publish is not deduplicated.
+// If the key was published already, the original result is replayed; either way, on !ok the response is written
+func (stub *Stub) claimPublish(ctx *fasthttp.RequestCtx, tag string) (string, bool) {
+	raw := ctx.Request.Header.Peek("X-Idempotency-Key")
+	if len(raw) == 0 || stub.idempotencyWindow <= 0 {
+		return "", true
+	}
+
+	// the same rules as for the request IDs
+	if !validRequestID(raw) {
+		setError(ctx, http.StatusBadRequest)
+		writeError(ctx, CodeInvalidArgument, "X-Idempotency-Key must be up to 128 URL-safe characters")
+
+		return "", false
+	}
+
+	key := string(raw)
+
+	c, cancel := context.WithTimeout(context.Background(), idempotencyTimeout)
+	defer cancel()
+
+	state, err := stub.idempotency.Claim(c, tag, key)
+	if err != nil {
+		// a failing store doesn't block the publishes
+		requestLogger(ctx).Warn("idempotency store is unavailable", zap.Error(err))
+		return "", true
+	}
+
+	switch state {
+	case idempotency.Completed:
+		ctx.Response.Header.Set("X-Idempotent-Replay", "true")
+
+		response := struct{ hasCode }{}
+		writeJSON(ctx, response)
+
+		return "", false
+
+	case idempotency.Pending:
+		setError(ctx, http.StatusConflict)
+		writeError(ctx, CodePublishInProgress, "a publish with the same X-Idempotency-Key is in progress")
+
+		return "", false
+
+	default:
+		return key, true
+	}
+}
+
+// finishPublish remembers the claimed key if the publish succeeded, otherwise lets it be retried
+func (stub *Stub) finishPublish(ctx *fasthttp.RequestCtx, tag, key string, published bool) {
+	if len(key) == 0 {
+		return
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), idempotencyTimeout)
+	defer cancel()
+
+	var err error
+
+	if published {
+		err = stub.idempotency.Complete(c, tag, key, stub.idempotencyWindow)
+	} else {
+		err = stub.idempotency.Release(c, tag, key)
+	}
+
+	if err != nil {
+		requestLogger(ctx).Warn("unable to update the idempotency key", zap.Error(err))
+	}
+}
@@ -2,11 +2,18 @@ package api
 
 import (
 	"encoding/json"
+	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
 	"io"
 )
 
 func writeJSON(w io.Writer, data any) {
+	if ctx, ok := w.(*fasthttp.RequestCtx); ok {
+		if c, ok := data.(apiCoder); ok {
+			setAPICode(ctx, c.apiCode())
+		}
+	}
+
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "    ")
 
@@ -6,66 +6,103 @@ import (
 	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
 	"io"
+	"limq/authenticator"
+	"limq/metrics"
+	"limq/quota"
 	"net/http"
 	"strconv"
 	"time"
 )
 
-const defaultTimeout = 25
+// waitTimeout is the X-Timeout of a blocking request (in seconds), the listen timeout quota by default
+func waitTimeout(ctx *fasthttp.RequestCtx) time.Duration {
+	seconds, err := strconv.Atoi(string(ctx.Request.Header.Peek("X-Timeout")))
+	if err != nil {
+		return quota.Current().ListenTimeout
+	}
+
+	return time.Duration(seconds) * time.Second
+}
 
 func (stub *Stub) listen(ctx *fasthttp.RequestCtx) {
 	key := ctx.UserValue("access_key").(string)
 
-	auth := stub.auth.CheckAccessKey(key)
-	if !auth.Flags.Active() || len(auth.Tag) == 0 {
-		setError(ctx, http.StatusUnauthorized)
-		writeError(ctx, CodeAuthenticationError, "access key is suspended or invalid")
-
+	if stub.rejectDraining(ctx) {
 		return
 	}
 
-	if !auth.Flags.CanListen() {
-		setError(ctx, http.StatusForbidden)
-		writeError(ctx, CodeAuthenticationError, "no listen permissions")
+	auth, ok := stub.authorize(ctx, key, authenticator.AccessLevel.CanListen, "no listen permissions")
+	if !ok {
+		return
+	}
 
+	release, ok := stub.limit(ctx, key, auth, limitListen, 0)
+	if !ok {
 		return
 	}
 
-	if !stub.ea.start(key) {
-		setError(ctx, http.StatusConflict)
-		writeError(ctx, CodeAnotherClientIsOnline, "this access key is being used by another listener right now")
+	defer release()
 
+	lease, ok := stub.acquireListener(ctx, key, auth)
+	if !ok {
 		return
 	}
 
-	defer stub.ea.stop(key)
+	defer lease.Release()
 
-	timeoutValue := ctx.Request.Header.Peek("X-Timeout")
+	listenCtx, cancel := context.WithTimeout(context.Background(), waitTimeout(ctx))
+	defer cancel()
 
-	seconds, err := strconv.Atoi(string(timeoutValue))
-	if err != nil {
-		seconds = defaultTimeout
-	}
+	go func() {
+		select {
+		case <-lease.Kicked():
+			cancel()
 
-	timeout := time.Duration(seconds) * time.Second
-	listenCtx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+		case <-stub.bufferedBroker.Draining():
+			cancel()
+
+		case <-listenCtx.Done():
+		}
+	}()
 
 	{
 		m := stub.bufferedBroker.Listen(listenCtx, auth.Tag)
 		if m == nil {
-			ctx.SetStatusCode(http.StatusNotModified)
+			select {
+			case <-lease.Kicked():
+				setError(ctx, http.StatusConflict)
+				writeError(ctx, CodeAnotherClientIsOnline, reasonTakenOver)
+
+			default:
+				ctx.SetStatusCode(http.StatusNotModified)
+				setAPICode(ctx, CodeTimeout)
+			}
+
 			return
 		}
 
+		span, traceparent := startDelivery(m, "poll")
+		defer span.End()
+
 		ctx.SetContentType("application/x-octet-stream")
 		ctx.Response.Header.Set("X-Message-Scope", m.Scope.String())
 		ctx.Response.Header.Set("X-Message-Type", m.Type.String())
+		writeMetaHeaders(ctx, m)
+		writeProvenanceHeaders(ctx, m)
+		writeCorrelationHeaders(ctx, m)
+
+		if len(traceparent) > 0 {
+			ctx.Response.Header.Set("traceparent", traceparent)
+		}
 
 		_, err := io.Copy(ctx, bytes.NewReader(m.Payload))
 		if err != nil {
-			zap.L().Error("can't drop buffer", zap.String("chan_id", m.ChannelID), zap.Error(err))
+			requestLogger(ctx).Error("can't drop buffer", zap.String("chan_id", m.ChannelID), zap.Error(err))
+			span.SetError(err)
+
 			return
 		}
+
+		metrics.PayloadBytes.Add(float64(len(m.Payload)), "delivered")
 	}
 }
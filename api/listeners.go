@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"limq/authenticator"
+	"limq/listeners"
+	"net/http"
+)
+
+const reasonTakenOver = "the listener is taken over by a newer connection"
+
+// acquireListener registers the listener according to the key's listener policy,
+// the listeners holding tokens minted from the key count as the key's ones.
+// On failure the error response is already written
+func (stub *Stub) acquireListener(ctx *fasthttp.RequestCtx, key string, auth authenticator.Descriptor) (listeners.Lease, bool) {
+	policy := auth.Listeners.Resolve(stub.listenerPolicy)
+
+	c, cancel := context.WithTimeout(context.Background(), limiterTimeout)
+	defer cancel()
+
+	lease, err := stub.listeners.Acquire(c, ownerID(key, auth), policy)
+	if err != nil {
+		if !errors.Is(err, listeners.ErrBusy) {
+			requestLogger(ctx).Error("unable to register the listener", zap.Error(err))
+		}
+
+		setError(ctx, http.StatusConflict)
+		writeError(ctx, CodeAnotherClientIsOnline, "this access key has reached its online listeners limit ("+policy.String()+")")
+
+		return nil, false
+	}
+
+	return lease, true
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"github.com/valyala/fasthttp"
+	"limq/authenticator"
+	"limq/listeners"
+	"testing"
+)
+
+func TestTokenSharesKeyListeners(t *testing.T) {
+	stub := newTestStub(t, []authenticator.StaticKey{{
+		Key:         testKey,
+		Tag:         testTag,
+		Permissions: authenticator.AccessRead,
+		Listeners:   listeners.Policy{Mode: listeners.ModeShared, Max: 2},
+	}}, Options{})
+
+	first, second := mintToken(t, stub, testKey, "1"), mintToken(t, stub, testKey, "1")
+
+	// the key's shared:2 policy holds for the key and its tokens together
+	for i, key := range []string{testKey, first, second} {
+		lease, ok := stub.acquireListener(&fasthttp.RequestCtx{}, key, stub.auth.CheckAccessKey(key))
+
+		if ok != (i < 2) {
+			t.Fatalf("listener #%d: unexpected lease result %v", i+1, ok)
+		}
+
+		if ok {
+			defer lease.Release()
+		}
+	}
+}
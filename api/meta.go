@@ -0,0 +1,105 @@
+package api
+
+import (
+	"github.com/valyala/fasthttp"
+	"limq/message"
+	"limq/quota"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// metaHeaderPrefix marks the request headers carried along with the message as its user headers
+const metaHeaderPrefix = "X-Meta-"
+
+func isMetaHeader(name string) bool {
+	return len(name) > len(metaHeaderPrefix) && strings.EqualFold(name[:len(metaHeaderPrefix)], metaHeaderPrefix)
+}
+
+// readMetaHeaders collects the user headers of a publish request, names are lowercase
+func readMetaHeaders(ctx *fasthttp.RequestCtx) (map[string]string, bool) {
+	var (
+		headers map[string]string
+		size    int
+	)
+
+	ctx.Request.Header.VisitAll(func(k, v []byte) {
+		name := string(k)
+		if !isMetaHeader(name) {
+			return
+		}
+
+		if headers == nil {
+			headers = map[string]string{}
+		}
+
+		headers[strings.ToLower(name[len(metaHeaderPrefix):])] = string(v)
+		size += len(k) + len(v)
+	})
+
+	if q := quota.Current(); len(headers) > q.MaxMessageHeaders || size > q.MaxHeadersSize {
+		setError(ctx, http.StatusBadRequest)
+		writeError(ctx, CodeInvalidArgument, "too many or too large "+metaHeaderPrefix+" headers")
+
+		return nil, false
+	}
+
+	return headers, true
+}
+
+// writeMetaHeaders sets the user headers of m and exposes them to the browsers
+func writeMetaHeaders(ctx *fasthttp.RequestCtx, m *message.Message) {
+	if len(m.Headers) == 0 {
+		return
+	}
+
+	exposed := string(ctx.Response.Header.Peek("Access-Control-Expose-Headers"))
+
+	for name, value := range m.Headers {
+		header := metaHeaderPrefix + name
+
+		ctx.Response.Header.Set(header, value)
+
+		if len(exposed) > 0 {
+			exposed += ", " + header
+		}
+	}
+
+	if len(exposed) > 0 {
+		ctx.Response.Header.Set("Access-Control-Expose-Headers", exposed)
+	}
+}
+
+// writeProvenanceHeaders describes the forwarding path of m, if it was forwarded
+func writeProvenanceHeaders(ctx *fasthttp.RequestCtx, m *message.Message) {
+	if m.Hops() == 0 {
+		return
+	}
+
+	ctx.Response.Header.Set("X-Origin-Channel", m.Origin())
+	ctx.Response.Header.Set("X-Forward-Hops", strconv.Itoa(m.Hops()))
+	ctx.Response.Header.Set("X-Forward-Path", strings.Join(m.Path, ","))
+}
+
+// writeCorrelationHeaders tells where to reply to m, if it's a request
+func writeCorrelationHeaders(ctx *fasthttp.RequestCtx, m *message.Message) {
+	if len(m.CorrelationID) == 0 {
+		return
+	}
+
+	ctx.Response.Header.Set("X-Correlation-ID", m.CorrelationID)
+	ctx.Response.Header.Set("X-Reply-To", replyRoute)
+}
+
+// allowedRequestHeaders appends the requested user headers to the allowed ones for a preflight
+func allowedRequestHeaders(ctx *fasthttp.RequestCtx) string {
+	allowed := corsAllowHeaders
+
+	for _, name := range strings.Split(string(ctx.Request.Header.Peek("Access-Control-Request-Headers")), ",") {
+		if name = strings.TrimSpace(name); isMetaHeader(name) {
+			allowed += ", " + name
+		}
+	}
+
+	return allowed
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+	"limq/metrics"
+	"strconv"
+	"time"
+)
+
+const metricsTimeout = 5 * time.Second
+
+// apiCodeKey is the user value holding the API status code of the response
+const apiCodeKey = "limq_api_code"
+
+// apiCoder is implemented by the responses embedding hasCode
+type apiCoder interface {
+	apiCode() int
+}
+
+func (c hasCode) apiCode() int {
+	return c.Code
+}
+
+func setAPICode(ctx *fasthttp.RequestCtx, code int) {
+	ctx.SetUserValue(apiCodeKey, code)
+}
+
+// resultCode returns the API status code of the response, derived from the HTTP status if none was written
+func resultCode(ctx *fasthttp.RequestCtx) int {
+	if code, ok := ctx.UserValue(apiCodeKey).(int); ok {
+		return code
+	}
+
+	if ctx.Response.StatusCode() < 400 {
+		return CodeOk
+	}
+
+	return CodeUnknownError
+}
+
+func countPublishes(f func(ctx *fasthttp.RequestCtx)) func(ctx *fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		f(ctx)
+
+		metrics.Publishes.Inc(strconv.Itoa(resultCode(ctx)))
+	}
+}
+
+func countListens(mode string, f func(ctx *fasthttp.RequestCtx)) func(ctx *fasthttp.RequestCtx) {
+	return func(ctx *fasthttp.RequestCtx) {
+		f(ctx)
+
+		metrics.Listens.Inc(mode, strconv.Itoa(resultCode(ctx)))
+	}
+}
+
+// MetricsHandler serves the Prometheus /metrics, it's meant for a separate admin listen address.
+// perChannel enables the metrics labelled by channel tag
+func (stub *Stub) MetricsHandler(perChannel bool) func(ctx *fasthttp.RequestCtx) {
+	r := router.New()
+
+	r.GET("/metrics", func(ctx *fasthttp.RequestCtx) {
+		c, cancel := context.WithTimeout(context.Background(), metricsTimeout)
+		defer cancel()
+
+		stub.bufferedBroker.CollectMetrics(c, perChannel)
+
+		ctx.SetContentType("text/plain; version=0.0.4; charset=utf-8")
+
+		if _, err := metrics.Default.WriteTo(ctx); err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+		}
+	})
+
+	return r.Handler
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"github.com/valyala/fasthttp"
+	"limq/authenticator"
+	"net/http"
+)
+
+// originAllowed checks the request origin against the global CORS policy
+// narrowed down by the channel's own origins list, if the channel has one.
+// On failure the error response is already written
+func (stub *Stub) originAllowed(ctx *fasthttp.RequestCtx, auth authenticator.Descriptor) bool {
+	origin := string(ctx.Request.Header.Peek("Origin"))
+
+	if stub.corsPolicy().Allows(origin) && (len(origin) == 0 || len(auth.Origins) == 0 || originListed(auth.Origins, origin)) {
+		return true
+	}
+
+	ctx.Response.Header.Del("Access-Control-Allow-Origin")
+	ctx.Response.Header.Del("Access-Control-Allow-Credentials")
+
+	setError(ctx, http.StatusForbidden)
+	writeError(ctx, CodeOriginNotAllowed, "origin is not allowed for this channel")
+
+	return false
+}
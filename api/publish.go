@@ -2,57 +2,74 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
+	"limq/audit"
+	"limq/authenticator"
 	"limq/broker"
 	"limq/message"
+	"limq/metrics"
+	"limq/tracing"
 	"net/http"
 )
 
+// readMessageType parses the x-message-type header, binary by default.
+// On failure the error response is already written
+func readMessageType(ctx *fasthttp.RequestCtx) (message.Type, bool) {
+	typ, ok := message.ParseType(string(ctx.Request.Header.Peek("x-message-type")))
+	if !ok {
+		setError(ctx, http.StatusBadRequest)
+		writeError(ctx, CodeUnknownMessageType, "unknown message type")
+	}
+
+	return typ, ok
+}
+
 func (stub *Stub) publish(ctx *fasthttp.RequestCtx) {
 	key := ctx.UserValue("access_key").(string)
 
 	defer ctx.SetContentTypeBytes(strApplicationJSON)
 
-	auth := stub.auth.CheckAccessKey(key)
-	if !auth.Flags.Active() || len(auth.Tag) == 0 {
-		setError(ctx, http.StatusUnauthorized)
-		writeError(ctx, CodeAuthenticationError, "access key is suspended or invalid")
-
+	auth, ok := stub.authorize(ctx, key, authenticator.AccessLevel.CanPublish, "no publish permissions")
+	if !ok {
 		return
 	}
 
-	if !auth.Flags.CanPublish() {
-		setError(ctx, http.StatusForbidden)
-		writeError(ctx, CodeAuthenticationError, "no publish permissions")
-
+	release, ok := stub.limit(ctx, key, auth, limitPublish, len(ctx.PostBody()))
+	if !ok {
 		return
 	}
 
-	var (
-		typ   message.Type
-		scope message.Scope
-	)
+	defer release()
 
-	{
-		ok := false
+	typ, ok := readMessageType(ctx)
+	if !ok {
+		return
+	}
 
-		messageTypeRaw := ctx.Request.Header.Peek("x-message-type")
-		typ, ok = message.ParseType(string(messageTypeRaw))
-		if !ok {
-			setError(ctx, http.StatusBadRequest)
-			writeError(ctx, CodeUnknownMessageType, "unknown message type")
+	scope := message.ParseScope(string(ctx.Request.Header.Peek("x-scope")))
 
-			return
-		}
+	headers, ok := readMetaHeaders(ctx)
+	if !ok {
+		return
 	}
 
-	{
-		scopeRaw := string(ctx.Request.Header.Peek("x-scope"))
-		scope = message.ParseScope(scopeRaw)
+	idempotencyKey, ok := stub.claimPublish(ctx, auth.Tag)
+	if !ok {
+		return
 	}
 
-	m := &message.Message{ChannelID: auth.Tag, Type: typ, Scope: scope}
+	traceparent := string(ctx.Request.Header.Peek("traceparent"))
+
+	span := tracing.Start(traceparent, "limq.publish", tracing.KindProducer)
+	span.SetAttribute("limq.channel", auth.Tag)
+	span.SetAttribute("limq.message_type", typ.String())
+
+	defer span.End()
+
+	m := &message.Message{ChannelID: auth.Tag, Type: typ, Scope: scope, Headers: headers,
+		TraceParent: tracing.TraceparentOf(span, traceparent)}
 
 	{
 		body := ctx.PostBody()
@@ -60,31 +77,36 @@ func (stub *Stub) publish(ctx *fasthttp.RequestCtx) {
 		copy(m.Payload, body)
 
 		err := stub.bufferedBroker.PublishWithMixin(auth.Tag, m)
+		span.SetError(err)
+
+		stub.finishPublish(ctx, auth.Tag, idempotencyKey, err == nil)
 
 		if err == nil {
+			metrics.PayloadBytes.Add(float64(len(m.Payload)), "published")
+
+			if stub.audit.RecordsPublishes() {
+				stub.record(ctx, audit.KindPublish, auth.Tag, key, fmt.Sprintf("%s message of %d bytes", m.Type, len(m.Payload)))
+			}
+
 			response := struct{ hasCode }{}
 			writeJSON(ctx, response)
 
 		} else {
-			response := statusCodeWithText{}
-
 			if errors.Is(err, broker.ErrMessageIsEmpty) {
-				response.Code = CodeMessageIsEmpty
-				response.StatusText = "Message body is empty"
+				setError(ctx, http.StatusBadRequest)
+				writeError(ctx, CodeMessageIsEmpty, "Message body is empty")
 
 			} else if errors.Is(err, broker.ErrMessageIsTooLarge) {
-				response.Code = CodeMessageIsTooBig
-				response.StatusText = "Message is too large"
+				setError(ctx, http.StatusRequestEntityTooLarge)
+				writeError(ctx, CodeMessageIsTooBig, "Message is too large")
 
 			} else {
-				response.Code = CodeUnknownError
-				response.StatusText = "Unable to publish the message due to server error"
+				setError(ctx, http.StatusInternalServerError)
+				writeError(ctx, CodeUnknownError, "Unable to publish the message due to server error")
 
 			}
 
-			writeJSON(ctx, response)
-
-			zap.L().Error("unable to publish the message", zap.Error(err))
+			requestLogger(ctx).Error("unable to publish the message", zap.Error(err))
 		}
 	}
 }
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"encoding/json"
+	"limq/authenticator"
+	"net/http"
+	"testing"
+)
+
+func TestPublishErrorRequestID(t *testing.T) {
+	stub := newTestStub(t, []authenticator.StaticKey{{Key: testKey, Tag: testTag, Permissions: authenticator.AccessWrite}}, Options{})
+
+	resp := serve(stub, http.MethodPost, "/publish"+testKey, map[string]string{"X-Request-ID": "empty-body"}, "")
+
+	body := statusCodeWithText{}
+	if err := json.Unmarshal(resp.Body(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != http.StatusBadRequest || body.Code != CodeMessageIsEmpty || body.RequestID != "empty-body" {
+		t.Errorf("unexpected error %d %s", resp.StatusCode(), resp.Body())
+	}
+}
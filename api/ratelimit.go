@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"github.com/valyala/fasthttp"
+	"limq/authenticator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	limitPublish = "pub_"
+	limitListen  = "lis_"
+	limitToken   = "tok_"
+)
+
+const limiterTimeout = 500 * time.Millisecond
+
+func noRelease() {}
+
+func writeRateLimited(ctx *fasthttp.RequestCtx, retryAfter time.Duration, reason string) {
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	ctx.Response.Header.Set("Retry-After", strconv.Itoa(seconds))
+
+	setError(ctx, http.StatusTooManyRequests)
+	writeError(ctx, CodeRateLimited, reason)
+}
+
+// limit applies the key's rate limits to a request of the kind (limitPublish, limitListen or limitToken) carrying size bytes.
+// The tokens are counted against the access key they are minted from.
+// The returned func frees the concurrent request slot. On failure the error response is already written
+func (stub *Stub) limit(ctx *fasthttp.RequestCtx, key string, auth authenticator.Descriptor, kind string, size int) (func(), bool) {
+	if stub.limiter == nil {
+		return noRelease, true
+	}
+
+	limits := auth.Limits.Resolve(stub.defaultLimits())
+	id := ownerID(key, auth)
+
+	c, cancel := context.WithTimeout(context.Background(), limiterTimeout)
+	defer cancel()
+
+	retryAfter, err := stub.limiter.Allow(c, kind+id, limits, size)
+	if err != nil {
+		writeRateLimited(ctx, retryAfter, "rate limit is exceeded")
+		return nil, false
+	}
+
+	release, err := stub.limiter.Acquire(c, id, limits)
+	if err != nil {
+		writeRateLimited(ctx, time.Second, "too many concurrent requests")
+		return nil, false
+	}
+
+	return release, true
+}
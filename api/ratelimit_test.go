@@ -0,0 +1,31 @@
+package api
+
+import (
+	"limq/authenticator"
+	"limq/quota"
+	"limq/ratelimit"
+	"net/http"
+	"testing"
+)
+
+func TestTokenSharesKeyLimits(t *testing.T) {
+	stub := newTestStub(t, []authenticator.StaticKey{{
+		Key:         testKey,
+		Tag:         testTag,
+		Permissions: authenticator.AccessRead | authenticator.AccessWrite,
+		Limits:      quota.Limits{MessagesPerSecond: 2},
+	}}, Options{Limiter: ratelimit.NewLocal()})
+
+	first, second := mintToken(t, stub, testKey, "3"), mintToken(t, stub, testKey, "3")
+
+	for _, key := range []string{testKey, first} {
+		if resp := serve(stub, http.MethodPost, "/publish"+key, nil, "hello"); resp.StatusCode() != http.StatusOK {
+			t.Fatalf("unexpected status %d %s", resp.StatusCode(), resp.Body())
+		}
+	}
+
+	// the budget of 2 messages is spent by the key and its first token together
+	if resp := serve(stub, http.MethodPost, "/publish"+second, nil, "hello"); resp.StatusCode() != http.StatusTooManyRequests {
+		t.Errorf("a fresh token must not reset the key's budget, got %d %s", resp.StatusCode(), resp.Body())
+	}
+}
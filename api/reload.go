@@ -0,0 +1,56 @@
+package api
+
+import (
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"limq/audit"
+	"limq/config"
+	"limq/quota"
+	"net/http"
+	"strings"
+)
+
+type reloadResponse struct {
+	hasCode
+	config.Report
+}
+
+// Reconfigure swaps the global CORS policy and the default rate limits of a running stub
+func (stub *Stub) Reconfigure(cors CorsPolicy, limits quota.Limits) {
+	stub.tunables.Lock()
+	defer stub.tunables.Unlock()
+
+	stub.cors = &cors
+	stub.limits = limits
+}
+
+func (stub *Stub) corsPolicy() *CorsPolicy {
+	stub.tunables.RLock()
+	defer stub.tunables.RUnlock()
+
+	return stub.cors
+}
+
+func (stub *Stub) defaultLimits() quota.Limits {
+	stub.tunables.RLock()
+	defer stub.tunables.RUnlock()
+
+	return stub.limits
+}
+
+// adminReload does the same as SIGHUP and shows which settings are applied and which need a restart
+func (stub *Stub) adminReload(ctx *fasthttp.RequestCtx) {
+	report, err := stub.reload()
+	if err != nil {
+		requestLogger(ctx).Warn("unable to reload the configuration", zap.Error(err))
+
+		setError(ctx, http.StatusBadRequest)
+		writeError(ctx, CodeInvalidArgument, err.Error())
+
+		return
+	}
+
+	stub.record(ctx, audit.KindAdmin, "", "", "reloaded: "+strings.Join(report.Applied, ","))
+
+	writeJSON(ctx, reloadResponse{Report: report})
+}
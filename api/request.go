@@ -0,0 +1,233 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"io"
+	"limq/authenticator"
+	"limq/broker"
+	"limq/message"
+	"limq/metrics"
+	"limq/quota"
+	"limq/replies"
+	"limq/tracing"
+	"net/http"
+	"time"
+)
+
+// replyRoute is the reply-to address of the requests, the responder appends its access key
+const replyRoute = "/reply"
+
+const replyTimeout = 500 * time.Millisecond
+
+// request publishes a message awaiting a reply and blocks until the reply comes or X-Timeout passes.
+// The message is delivered with a fresh correlation ID, the responder replies to it through /reply.
+// Requests go to one listener unless x-scope says otherwise
+func (stub *Stub) request(ctx *fasthttp.RequestCtx) {
+	key := ctx.UserValue("access_key").(string)
+
+	if stub.rejectDraining(ctx) {
+		return
+	}
+
+	auth, ok := stub.authorize(ctx, key, authenticator.AccessLevel.CanPublish, "no publish permissions")
+	if !ok {
+		return
+	}
+
+	release, ok := stub.limit(ctx, key, auth, limitPublish, len(ctx.PostBody()))
+	if !ok {
+		return
+	}
+
+	defer release()
+
+	typ, ok := readMessageType(ctx)
+	if !ok {
+		return
+	}
+
+	scope := message.ScopeNotifyOne
+	if raw := ctx.Request.Header.Peek("x-scope"); len(raw) > 0 {
+		scope = message.ParseScope(string(raw))
+	}
+
+	headers, ok := readMetaHeaders(ctx)
+	if !ok {
+		return
+	}
+
+	// the pending request must expire, it is never awaited forever
+	timeout := waitTimeout(ctx)
+	if timeout <= 0 {
+		setError(ctx, http.StatusBadRequest)
+		writeError(ctx, CodeInvalidArgument, "X-Timeout must be a positive number of seconds")
+
+		return
+	}
+
+	id := replies.NewCorrelationID()
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// awaited before the publish, a quick reply must not be missed
+	pending, err := stub.replies.Await(waitCtx, id, timeout)
+	if err != nil {
+		requestLogger(ctx).Error("unable to await a reply", zap.Error(err))
+
+		setError(ctx, http.StatusInternalServerError)
+		writeError(ctx, CodeUnknownError, "Unable to publish the request due to server error")
+
+		return
+	}
+
+	defer pending.Done()
+
+	traceparent := string(ctx.Request.Header.Peek("traceparent"))
+
+	span := tracing.Start(traceparent, "limq.request", tracing.KindProducer)
+	span.SetAttribute("limq.channel", auth.Tag)
+
+	defer span.End()
+
+	m := &message.Message{ChannelID: auth.Tag, Type: typ, Scope: scope, Headers: headers,
+		TraceParent: tracing.TraceparentOf(span, traceparent), CorrelationID: id}
+
+	body := ctx.PostBody()
+	m.Payload = make([]byte, len(body))
+	copy(m.Payload, body)
+
+	if err := stub.bufferedBroker.PublishWithMixin(auth.Tag, m); err != nil {
+		span.SetError(err)
+
+		if errors.Is(err, broker.ErrMessageIsEmpty) {
+			setError(ctx, http.StatusBadRequest)
+			writeError(ctx, CodeMessageIsEmpty, "Message body is empty")
+
+		} else if errors.Is(err, broker.ErrMessageIsTooLarge) {
+			setError(ctx, http.StatusRequestEntityTooLarge)
+			writeError(ctx, CodeMessageIsTooBig, "Message is too large")
+
+		} else {
+			requestLogger(ctx).Error("unable to publish the request", zap.Error(err))
+
+			setError(ctx, http.StatusInternalServerError)
+			writeError(ctx, CodeUnknownError, "Unable to publish the request due to server error")
+
+		}
+
+		return
+	}
+
+	metrics.PayloadBytes.Add(float64(len(m.Payload)), "published")
+
+	select {
+	case reply := <-pending.Reply():
+		ctx.SetContentType("application/x-octet-stream")
+		ctx.Response.Header.Set("X-Message-Type", reply.Type.String())
+		ctx.Response.Header.Set("X-Correlation-ID", id)
+		writeMetaHeaders(ctx, reply)
+
+		if len(reply.TraceParent) > 0 {
+			ctx.Response.Header.Set("traceparent", reply.TraceParent)
+		}
+
+		if _, err := io.Copy(ctx, bytes.NewReader(reply.Payload)); err != nil {
+			requestLogger(ctx).Error("can't write the reply", zap.Error(err))
+			span.SetError(err)
+
+			return
+		}
+
+		metrics.PayloadBytes.Add(float64(len(reply.Payload)), "delivered")
+
+	case <-stub.bufferedBroker.Draining():
+		ctx.Response.Header.Set("Retry-After", "1")
+
+		setError(ctx, http.StatusServiceUnavailable)
+		writeError(ctx, CodeShuttingDown, reasonShuttingDown)
+
+	case <-waitCtx.Done():
+		ctx.Response.Header.Set("X-Correlation-ID", id)
+
+		setError(ctx, http.StatusGatewayTimeout)
+		writeError(ctx, CodeTimeout, "no reply within the timeout")
+	}
+}
+
+// reply hands the reply over to the request with the X-Correlation-ID.
+// Any valid access key may reply, the correlation ID is known to the request's recipients only
+func (stub *Stub) reply(ctx *fasthttp.RequestCtx) {
+	key := ctx.UserValue("access_key").(string)
+
+	defer ctx.SetContentTypeBytes(strApplicationJSON)
+
+	auth, ok := stub.authenticate(ctx, key)
+	if !ok {
+		return
+	}
+
+	release, ok := stub.limit(ctx, key, auth, limitPublish, len(ctx.PostBody()))
+	if !ok {
+		return
+	}
+
+	defer release()
+
+	id := string(ctx.Request.Header.Peek("X-Correlation-ID"))
+	if len(id) == 0 {
+		setError(ctx, http.StatusBadRequest)
+		writeError(ctx, CodeInvalidArgument, "X-Correlation-ID is required")
+
+		return
+	}
+
+	typ, ok := readMessageType(ctx)
+	if !ok {
+		return
+	}
+
+	headers, ok := readMetaHeaders(ctx)
+	if !ok {
+		return
+	}
+
+	body := ctx.PostBody()
+	if len(body) > quota.Current().MaxMessageSize {
+		setError(ctx, http.StatusRequestEntityTooLarge)
+		writeError(ctx, CodeMessageIsTooBig, "Message is too large")
+
+		return
+	}
+
+	m := &message.Message{Type: typ, Headers: headers, TraceParent: string(ctx.Request.Header.Peek("traceparent"))}
+	m.Payload = make([]byte, len(body))
+	copy(m.Payload, body)
+
+	c, cancel := context.WithTimeout(context.Background(), replyTimeout)
+	defer cancel()
+
+	err := stub.replies.Reply(c, id, m)
+	if errors.Is(err, replies.ErrNoRequest) {
+		setError(ctx, http.StatusNotFound)
+		writeError(ctx, CodeNotFound, "no request awaits the reply, it may have timed out or been replied to")
+
+		return
+	}
+
+	if err != nil {
+		requestLogger(ctx).Error("unable to reply", zap.Error(err))
+
+		setError(ctx, http.StatusInternalServerError)
+		writeError(ctx, CodeUnknownError, "Unable to reply due to server error")
+
+		return
+	}
+
+	response := struct{ hasCode }{}
+	writeJSON(ctx, response)
+}
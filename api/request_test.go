@@ -0,0 +1,23 @@
+package api
+
+import (
+	"limq/authenticator"
+	"net/http"
+	"testing"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	stub := newTestStub(t, []authenticator.StaticKey{{Key: testKey, Tag: testTag, Permissions: authenticator.AccessWrite}}, Options{})
+
+	for _, timeout := range []string{"0", "-1"} {
+		resp := serve(stub, http.MethodPost, "/request"+testKey, map[string]string{"X-Timeout": timeout}, "ping")
+		if resp.StatusCode() != http.StatusBadRequest {
+			t.Errorf("X-Timeout %s: unexpected status %d %s", timeout, resp.StatusCode(), resp.Body())
+		}
+	}
+
+	resp := serve(stub, http.MethodPost, "/request"+testKey, map[string]string{"X-Timeout": "1"}, "ping")
+	if resp.StatusCode() != http.StatusGatewayTimeout {
+		t.Errorf("unexpected status %d %s", resp.StatusCode(), resp.Body())
+	}
+}
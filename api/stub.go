@@ -2,49 +2,204 @@ package api
 
 import (
 	"github.com/fasthttp/router"
-	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/fasthttp/websocket"
 	"github.com/valyala/fasthttp"
+	"limq/audit"
 	"limq/authenticator"
 	"limq/broker"
+	"limq/config"
+	"limq/idempotency"
+	"limq/listeners"
+	"limq/quota"
+	"limq/ratelimit"
+	"limq/replies"
+	"limq/storage"
+	"sync"
+	"time"
 )
 
 type Stub struct {
-	auth           *authenticator.A
+	auth           authenticator.Authenticator
+	keys           authenticator.KeyStore
 	bufferedBroker *broker.Mega
 	routes         *router.Router
-	ea             *exclusiveAccess
+	listeners      listeners.Registry
+	listenerPolicy listeners.Policy
+	upgrader       websocket.FastHTTPUpgrader
+	adminToken     string
+	limiter        ratelimit.Limiter
+	audit          *audit.Log
+	certs          *authenticator.CertMap
+
+	idempotency       idempotency.Store
+	idempotencyWindow time.Duration
+
+	replies replies.Router
+
+	// cors and limits are swapped by Reconfigure
+	tunables *sync.RWMutex
+	cors     *CorsPolicy
+	limits   quota.Limits
+	reload   func() (config.Report, error)
+
+	readiness    []ReadinessCheck
+	shuttingDown uint32
+
+	// subscriptions counts the WebSocket handlers, which requeue the undelivered messages on drain
+	subscriptions *sync.WaitGroup
+}
+
+// Options holds the tunables of the HTTP API
+type Options struct {
+	CORS CorsPolicy
+
+	// Storage keeps the buffered messages and the forward jobs, they are kept in memory if nil
+	Storage broker.Storage
+
+	// AdminToken enables the admin API, which is disabled when empty
+	AdminToken string
+	// KeyStore is managed by the admin API; nil if the authenticator backend is read-only
+	KeyStore authenticator.KeyStore
+
+	// Limiter enforces the rate limits, none are enforced if nil
+	Limiter ratelimit.Limiter
+	// Limits are the defaults for the keys without own limits
+	Limits quota.Limits
+
+	// Audit records security-relevant events, discarded if nil
+	Audit *audit.Log
+
+	// Listeners tracks the online listeners, a single replica registry is used if nil
+	Listeners listeners.Registry
+	// ListenerPolicy is the default for the keys without own policy, exclusive if not set
+	ListenerPolicy listeners.Policy
+
+	// Readiness lists the dependencies probed by /readyz
+	Readiness []ReadinessCheck
+
+	// MaxForwardHops limits the forwards a message may go through, broker.DefaultMaxHops if not set
+	MaxForwardHops int
+
+	// Idempotency remembers the X-Idempotency-Key of the publishes, a single replica store is used if nil
+	Idempotency idempotency.Store
+	// IdempotencyWindow is the time the publishes are remembered for, X-Idempotency-Key is ignored if 0
+	IdempotencyWindow time.Duration
+
+	// Replies routes the replies to the pending requests, a single replica router is used if nil
+	Replies replies.Router
+
+	// ClientCerts authenticates the mTLS clients on the routes without access keys, which are disabled if nil
+	ClientCerts *authenticator.CertMap
+
+	// Reload re-reads the configuration on POST /admin/reload, the endpoint is disabled if nil
+	Reload func() (config.Report, error)
 }
 
 func (stub *Stub) Handler() func(ctx *fasthttp.RequestCtx) {
-	return stub.routes.Handler
+	return AccessLogMiddleware(stub.routes.Handler)
 }
 
 var strApplicationJSON = []byte("application/json")
 
-func NewStub(pool *pgxpool.Pool, a *authenticator.A) *Stub {
+func NewStub(a authenticator.Authenticator, opts Options) *Stub {
+	store := opts.Storage
+	if store == nil {
+		store = storage.NewMemory()
+	}
+
 	s := &Stub{
 		auth:           a,
-		bufferedBroker: broker.NewMega(pool, a.CreateMixinManager()),
+		bufferedBroker: broker.NewMega(store, a.CreateMixinManager()),
+		cors:           &opts.CORS,
+		adminToken:     opts.AdminToken,
+		keys:           opts.KeyStore,
+		limiter:        opts.Limiter,
+		limits:         opts.Limits,
+		audit:          opts.Audit,
+		certs:          opts.ClientCerts,
+		idempotency:    opts.Idempotency,
+		replies:        opts.Replies,
+		tunables:       &sync.RWMutex{},
+		reload:         opts.Reload,
+		listeners:      opts.Listeners,
+		listenerPolicy: opts.ListenerPolicy.Resolve(listeners.Exclusive),
+		readiness:      opts.Readiness,
+		subscriptions:  &sync.WaitGroup{},
+	}
+
+	s.bufferedBroker.SetMaxHops(opts.MaxForwardHops)
+
+	if s.listeners == nil {
+		s.listeners = listeners.NewLocal()
+	}
+
+	if s.idempotency == nil {
+		s.idempotency = idempotency.NewLocal()
 	}
 
+	s.idempotencyWindow = opts.IdempotencyWindow
+
+	if s.replies == nil {
+		s.replies = replies.NewLocal()
+	}
+
+	s.upgrader = newUpgrader(s.corsPolicy)
+
 	r := router.New()
+	r.SaveMatchedRoutePath = true
 	s.routes = r
 
-	r.GET("/listen{access_key}", CorsMiddlewareAny(s.listen))
-	r.POST("/publish{access_key}", CorsMiddlewareAny(s.publish))
-	r.GET("/subscribe{access_key}", CorsMiddlewareAny(s.listenWS))
-	//r.GET("/purge{access_key}", CorsMiddlewareAny(s.purge))
+	cors := func(f func(ctx *fasthttp.RequestCtx)) func(ctx *fasthttp.RequestCtx) {
+		return func(ctx *fasthttp.RequestCtx) {
+			s.corsPolicy().apply(ctx)
+
+			f(ctx)
+		}
+	}
+
+	listen := countListens("poll", s.listen)
+	publish := countPublishes(s.publish)
+	subscribe := countListens("ws", s.listenWS)
+	request := countPublishes(s.request)
+
+	r.GET("/healthz", s.healthz)
+	r.GET("/readyz", s.readyz)
+
+	r.GET("/listen{access_key}", cors(listen))
+	r.POST("/publish{access_key}", cors(publish))
+	r.GET("/subscribe{access_key}", cors(subscribe))
+	r.POST("/token{access_key}", cors(s.token))
+	r.POST("/request{access_key}", cors(request))
+	r.POST(replyRoute+"{access_key}", cors(s.reply))
+
+	// the same for the channels granted besides the key's primary one
+	r.GET("/channel/{tag}/listen{access_key}", cors(listen))
+	r.POST("/channel/{tag}/publish{access_key}", cors(publish))
+	r.GET("/channel/{tag}/subscribe{access_key}", cors(subscribe))
+	r.POST("/channel/{tag}/token{access_key}", cors(s.token))
+	r.POST("/channel/{tag}/request{access_key}", cors(request))
+	//r.GET("/purge{access_key}", cors(s.purge))
+
+	if s.certs != nil {
+		s.registerCertRoutes(cors)
+	}
+
+	if len(s.adminToken) > 0 && s.keys != nil {
+		s.registerAdminRoutes()
+	}
+
+	if len(s.adminToken) > 0 && s.reload != nil {
+		r.POST("/admin/reload", s.adminMiddleware(s.adminReload))
+	}
 
 	r.HandleOPTIONS = true
-	r.GlobalOPTIONS = CorsMiddlewareAny(func(ctx *fasthttp.RequestCtx) {
+	r.GlobalOPTIONS = cors(func(ctx *fasthttp.RequestCtx) {
 		ctx.Response.Header.Set("Allow", "OPTIONS, GET, POST")
 	})
 
-	r.NotFound = CorsMiddlewareAny(func(ctx *fasthttp.RequestCtx) {
+	r.NotFound = cors(func(ctx *fasthttp.RequestCtx) {
 		ctx.Response.Header.Set("Allow", "OPTIONS, GET, POST")
 	})
 
-	s.ea = newEA()
-
 	return s
 }
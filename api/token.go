@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"github.com/valyala/fasthttp"
+	"limq/authenticator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type tokenResponse struct {
+	hasCode
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// token mints a short-lived signed token out of a long-lived access key.
+// Optional query args: permissions (AccessLevel bits, listen-only by default) and ttl (seconds)
+func (stub *Stub) token(ctx *fasthttp.RequestCtx) {
+	key := ctx.UserValue("access_key").(string)
+
+	defer ctx.SetContentTypeBytes(strApplicationJSON)
+
+	if authenticator.IsToken(key) {
+		setError(ctx, http.StatusForbidden)
+		writeError(ctx, CodeAuthenticationError, "tokens can't be minted out of another token")
+
+		return
+	}
+
+	auth, ok := stub.authorize(ctx, key, func(authenticator.AccessLevel) bool { return true }, "")
+	if !ok {
+		return
+	}
+
+	flags := authenticator.AccessLevel(authenticator.AccessRead)
+	ttl := authenticator.DefaultTokenTTL
+
+	args := ctx.QueryArgs()
+
+	if args.Has("permissions") {
+		raw, err := args.GetUint("permissions")
+		if err != nil {
+			setError(ctx, http.StatusBadRequest)
+			writeError(ctx, CodeInvalidArgument, "permissions must be a non-negative integer")
+
+			return
+		}
+
+		flags = authenticator.AccessLevel(raw)
+	}
+
+	if args.Has("ttl") {
+		seconds, err := strconv.Atoi(string(args.Peek("ttl")))
+		if err != nil || seconds <= 0 {
+			setError(ctx, http.StatusBadRequest)
+			writeError(ctx, CodeInvalidArgument, "ttl must be a positive number of seconds")
+
+			return
+		}
+
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	release, ok := stub.limit(ctx, key, auth, limitToken, 0)
+	if !ok {
+		return
+	}
+
+	defer release()
+
+	minter, ok := stub.auth.(authenticator.TokenMinter)
+	if !ok {
+		setError(ctx, http.StatusNotImplemented)
+		writeError(ctx, CodeUnknownError, "signed tokens are disabled on this server")
+
+		return
+	}
+
+	auth.Owner = keyID(key)
+
+	token, expires, err := minter.MintToken(auth, flags, ttl)
+	if err != nil {
+		if errors.Is(err, authenticator.ErrTokensDisabled) {
+			setError(ctx, http.StatusNotImplemented)
+			writeError(ctx, CodeUnknownError, "signed tokens are disabled on this server")
+
+		} else {
+			setError(ctx, http.StatusForbidden)
+			writeError(ctx, CodeAuthenticationError, "requested permissions exceed the access key permissions")
+
+		}
+
+		return
+	}
+
+	response := tokenResponse{Token: token, ExpiresAt: expires.Unix()}
+	writeJSON(ctx, response)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/valyala/fasthttp"
+	"limq/authenticator"
+	"net/http"
+	"testing"
+)
+
+const (
+	testKey = "0123456789abcdef0123456789abcdef"
+	testTag = "0123456789abcdef"
+)
+
+// newTestStub serves the keys with signed tokens enabled
+func newTestStub(t *testing.T, keys []authenticator.StaticKey, opts Options) *Stub {
+	t.Helper()
+
+	a, err := authenticator.NewStatic(authenticator.StaticConfig{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewStub(authenticator.NewSigned(a, []byte("secret")), opts)
+}
+
+// serve runs a single request through the stub's handler
+func serve(stub *Stub, method, uri string, headers map[string]string, body string) *fasthttp.Response {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	ctx.Request.SetBodyString(body)
+
+	for name, value := range headers {
+		ctx.Request.Header.Set(name, value)
+	}
+
+	stub.Handler()(ctx)
+
+	return &ctx.Response
+}
+
+func mintToken(t *testing.T, stub *Stub, key, permissions string) string {
+	t.Helper()
+
+	resp := serve(stub, http.MethodPost, "/token"+key+"?permissions="+permissions, nil, "")
+	if resp.StatusCode() != http.StatusOK {
+		t.Fatalf("unable to mint a token: %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	token := tokenResponse{}
+	if err := json.Unmarshal(resp.Body(), &token); err != nil {
+		t.Fatal(err)
+	}
+
+	return token.Token
+}
+
+func TestTokenOrigins(t *testing.T) {
+	stub := newTestStub(t, []authenticator.StaticKey{{
+		Key:         testKey,
+		Tag:         testTag,
+		Permissions: authenticator.AccessRead | authenticator.AccessWrite,
+		Origins:     []string{"https://a.example"},
+	}}, Options{CORS: CorsPolicy{AllowedOrigins: []string{"*"}}})
+
+	token := mintToken(t, stub, testKey, "3")
+
+	allowed := serve(stub, http.MethodPost, "/publish"+token, map[string]string{"Origin": "https://a.example"}, "hello")
+	if allowed.StatusCode() != http.StatusOK {
+		t.Errorf("a listed origin is rejected: %d %s", allowed.StatusCode(), allowed.Body())
+	}
+
+	for _, key := range []string{testKey, token} {
+		denied := serve(stub, http.MethodPost, "/publish"+key, map[string]string{"Origin": "https://b.example"}, "hello")
+		if denied.StatusCode() != http.StatusForbidden {
+			t.Errorf("an unlisted origin is allowed: %d %s", denied.StatusCode(), denied.Body())
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package api
+
+import (
+	"limq/message"
+	"limq/tracing"
+)
+
+// startDelivery starts the delivery span of m and returns the trace context handed to the consumer
+func startDelivery(m *message.Message, mode string) (*tracing.Span, string) {
+	span := tracing.Start(m.TraceParent, "limq.deliver", tracing.KindConsumer)
+	span.SetAttribute("limq.channel", m.ChannelID)
+	span.SetAttribute("limq.listen_mode", mode)
+
+	return span, tracing.TraceparentOf(span, m.TraceParent)
+}
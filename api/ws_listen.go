@@ -5,45 +5,168 @@ import (
 	"github.com/fasthttp/websocket"
 	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
+	"limq/authenticator"
 	"limq/message"
+	"limq/metrics"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
-var upgrader = websocket.FastHTTPUpgrader{
-	CheckOrigin: func(ctx *fasthttp.RequestCtx) bool {
-		return true // possibly unsafe
-	},
+// closeTakenOver is the close code sent to a listener kicked by a newer connection
+const closeTakenOver = 4000
+
+func newUpgrader(cors func() *CorsPolicy) websocket.FastHTTPUpgrader {
+	return websocket.FastHTTPUpgrader{
+		CheckOrigin: func(ctx *fasthttp.RequestCtx) bool {
+			return cors().Allows(string(ctx.Request.Header.Peek("Origin")))
+		},
+	}
 }
 
-func (stub *Stub) listenWS(ctx *fasthttp.RequestCtx) {
-	key := ctx.UserValue("access_key").(string)
+// subscribedChannels resolves the channels query arg: a comma-separated list of granted tags,
+// or "*" for every channel the key may listen to. The route's channel is the default.
+// On failure the error response is already written
+func (stub *Stub) subscribedChannels(ctx *fasthttp.RequestCtx, key string, auth authenticator.Descriptor) ([]string, bool) {
+	raw := string(ctx.QueryArgs().Peek("channels"))
+
+	if len(raw) == 0 {
+		channel, ok := stub.authorizeChannel(ctx, key, auth, requestedChannel(ctx), authenticator.AccessLevel.CanListen, "no listen permissions")
+		return []string{channel.Tag}, ok
+	}
 
-	auth := stub.auth.CheckAccessKey(key)
-	if !auth.Flags.Active() || len(auth.Tag) == 0 {
-		setError(ctx, http.StatusUnauthorized)
-		writeError(ctx, CodeAuthenticationError, "access key is suspended or invalid")
+	var tags []string
 
-		return
+	if raw == "*" {
+		for _, g := range auth.Grants() {
+			if g.Flags.CanListen() {
+				tags = append(tags, g.Tag)
+			}
+		}
+	} else {
+		tags = strings.Split(raw, ",")
 	}
 
-	if !auth.Flags.CanListen() {
+	if len(tags) == 0 {
 		setError(ctx, http.StatusForbidden)
 		writeError(ctx, CodeAuthenticationError, "no listen permissions")
 
+		return nil, false
+	}
+
+	for _, tag := range tags {
+		if _, ok := stub.authorizeChannel(ctx, key, auth, tag, authenticator.AccessLevel.CanListen, "no listen permissions for "+tag); !ok {
+			return nil, false
+		}
+	}
+
+	return tags, true
+}
+
+// mergeStreams multiplexes several listen streams into one, which is closed when all of them are
+func mergeStreams(streams []chan *message.Message) chan *message.Message {
+	if len(streams) == 1 {
+		return streams[0]
+	}
+
+	merged := make(chan *message.Message, len(streams))
+	wg := &sync.WaitGroup{}
+
+	for _, s := range streams {
+		wg.Add(1)
+
+		go func(s chan *message.Message) {
+			defer wg.Done()
+
+			for m := range s {
+				if m != nil {
+					merged <- m
+				}
+			}
+		}(s)
+	}
+
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	return merged
+}
+
+func (stub *Stub) listenWS(ctx *fasthttp.RequestCtx) {
+	key := ctx.UserValue("access_key").(string)
+
+	if stub.rejectDraining(ctx) {
+		return
+	}
+
+	auth, ok := stub.authenticate(ctx, key)
+	if !ok {
+		return
+	}
+
+	tags, ok := stub.subscribedChannels(ctx, key, auth)
+	if !ok {
 		return
 	}
 
-	if !stub.ea.start(key) {
-		setError(ctx, http.StatusConflict)
-		writeError(ctx, CodeAnotherClientIsOnline, "this access key is being used by another listener right now")
+	// multiplexed frames must say which channel they came from
+	useEnvelope := len(tags) > 1 || ctx.QueryArgs().GetBool("envelope")
+	logger := requestLogger(ctx).With(zap.Strings("tags", tags))
 
+	release, ok := stub.limit(ctx, key, auth, limitListen, 0)
+	if !ok {
 		return
 	}
 
-	err := upgrader.Upgrade(ctx, func(conn *websocket.Conn) {
+	lease, ok := stub.acquireListener(ctx, key, auth)
+	if !ok {
+		release()
+		return
+	}
+
+	stub.subscriptions.Add(1)
+
+	err := stub.upgrader.Upgrade(ctx, func(conn *websocket.Conn) {
+		defer stub.subscriptions.Done()
+
 		listenerContext, cancel := context.WithCancel(context.Background())
 
-		defer stub.ea.stop(key)
+		metrics.WSConnections.Add(1)
+		defer metrics.WSConnections.Add(-1)
+
+		defer lease.Release()
+		defer release()
+
+		watchdog := make(chan struct{})
+
+		// the conn is released once the handler returns, no close frame may be written after that
+		defer func() {
+			cancel()
+			<-watchdog
+		}()
+
+		go func() {
+			defer close(watchdog)
+
+			select {
+			case <-lease.Kicked():
+				closeMessage := websocket.FormatCloseMessage(closeTakenOver, reasonTakenOver)
+				_ = conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+
+				cancel()
+
+			case <-stub.bufferedBroker.Draining():
+				closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, reasonShuttingDown)
+				_ = conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+
+				cancel()
+
+			case <-listenerContext.Done():
+			}
+		}()
 
 		go func() {
 			defer cancel()
@@ -54,7 +177,7 @@ func (stub *Stub) listenWS(ctx *fasthttp.RequestCtx) {
 				_, _, err := conn.ReadMessage()
 				if err != nil {
 					if _, ok := err.(*websocket.CloseError); !ok {
-						zap.L().Error("ws error", zap.Error(err), zap.String("tag", auth.Tag))
+						logger.Error("ws error", zap.Error(err))
 					}
 
 					return
@@ -62,27 +185,70 @@ func (stub *Stub) listenWS(ctx *fasthttp.RequestCtx) {
 			}
 		}()
 
-		channel := stub.bufferedBroker.ListenStream(listenerContext, auth.Tag)
+		streams := make([]chan *message.Message, len(tags))
+		for i, tag := range tags {
+			streams[i] = stub.bufferedBroker.ListenStream(listenerContext, tag)
+		}
+
+		channel := mergeStreams(streams)
 
 		for m := range channel {
 			if m == nil {
 				if listenerContext.Err() == nil {
-					zap.L().Warn("invalid nil message", zap.String("tag", auth.Tag))
+					logger.Warn("invalid nil message")
 				}
 
 				break
 			}
 
-			err := conn.WriteMessage(message.TypeToWebSocketType(m.Type), m.Payload)
+			if listenerContext.Err() != nil {
+				// the listener is leaving, the message is kept for the others on drain
+				stub.requeueOnDrain(m)
+				break
+			}
+
+			var err error
+
+			span, traceparent := startDelivery(m, "ws")
+
+			if useEnvelope {
+				var frame []byte
+
+				e := newEnvelope(m)
+				e.TraceParent = traceparent
+
+				frame, err = e.encode()
+				if err == nil {
+					err = conn.WriteMessage(websocket.TextMessage, frame)
+				}
+			} else {
+				err = conn.WriteMessage(message.TypeToWebSocketType(m.Type), m.Payload)
+			}
+
+			span.SetError(err)
+			span.End()
+
 			if err != nil {
-				zap.L().Warn("unable to write message", zap.String("tag", auth.Tag))
+				logger.Warn("unable to write message")
+				stub.requeueOnDrain(m)
+
 				break
 			}
+
+			metrics.PayloadBytes.Add(float64(len(m.Payload)), "delivered")
+		}
+
+		// let the other streams of a multiplexed subscription wind down
+		cancel()
+		for m := range channel {
+			stub.requeueOnDrain(m)
 		}
 	})
 
 	if err != nil {
-		stub.ea.stop(key)
-		zap.L().Error("websocket upgrade", zap.Error(err))
+		stub.subscriptions.Done()
+		lease.Release()
+		release()
+		logger.Error("websocket upgrade", zap.Error(err))
 	}
 }
@@ -0,0 +1,28 @@
+package audit
+
+import "time"
+
+type Kind string
+
+const (
+	KindAuthFailed       Kind = "auth_failed"
+	KindPermissionDenied Kind = "permission_denied"
+	KindKeySuspended     Kind = "key_suspended"
+	KindKeyResumed       Kind = "key_resumed"
+	KindPurge            Kind = "purge"
+	KindAdmin            Kind = "admin"
+	KindPublish          Kind = "publish"
+)
+
+// Event is a single audit record
+type Event struct {
+	Time    time.Time `json:"time"`
+	Kind    Kind      `json:"kind"`
+	Channel string    `json:"channel_id,omitempty"`
+
+	// KeyPrefix identifies the access key by a prefix of its SHA-256, the key itself is never logged
+	KeyPrefix string `json:"key_prefix,omitempty"`
+	ClientIP  string `json:"client_ip,omitempty"`
+	Action    string `json:"action,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
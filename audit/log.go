@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Schema creates the table of the Postgres audit sink
+const Schema = `
+CREATE TABLE IF NOT EXISTS audit_log (
+	id         BIGSERIAL PRIMARY KEY,
+	time       TIMESTAMPTZ NOT NULL,
+	kind       TEXT NOT NULL,
+	channel    TEXT NOT NULL DEFAULT '',
+	key_prefix TEXT NOT NULL DEFAULT '',
+	client_ip  TEXT NOT NULL DEFAULT '',
+	action     TEXT NOT NULL DEFAULT '',
+	detail     TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_channel_time ON audit_log (channel, time);
+CREATE INDEX IF NOT EXISTS audit_log_time ON audit_log (time);
+`
+
+const (
+	queueSize    = 1024
+	writeTimeout = 2 * time.Second
+)
+
+var ErrQueryUnavailable = errors.New("audit events are not stored in the database")
+
+// Log writes the events to a dedicated zap logger and, optionally, to the audit_log table.
+// A nil *Log discards the events
+type Log struct {
+	logger  *zap.Logger
+	pool    *pgxpool.Pool
+	queue   chan Event
+	publish bool
+}
+
+// New creates an audit log. Database writes are asynchronous and are skipped when pool is nil.
+// Publish events are recorded only if publish is set
+func New(ctx context.Context, logger *zap.Logger, pool *pgxpool.Pool, publish bool) (*Log, error) {
+	l := &Log{logger: logger, pool: pool, publish: publish}
+
+	if pool != nil {
+		if _, err := pool.Exec(ctx, Schema); err != nil {
+			return nil, err
+		}
+
+		l.queue = make(chan Event, queueSize)
+		go l.store()
+	}
+
+	return l, nil
+}
+
+// RecordsPublishes reports whether publish events should be recorded
+func (l *Log) RecordsPublishes() bool {
+	return l != nil && l.publish
+}
+
+func (l *Log) Record(e Event) {
+	if l == nil {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	l.logger.Info(string(e.Kind),
+		zap.Time("time", e.Time),
+		zap.String("chan_id", e.Channel),
+		zap.String("key_prefix", e.KeyPrefix),
+		zap.String("client_ip", e.ClientIP),
+		zap.String("action", e.Action),
+		zap.String("detail", e.Detail),
+	)
+
+	if l.queue == nil {
+		return
+	}
+
+	select {
+	case l.queue <- e:
+	default:
+		l.logger.Warn("audit queue is full, the event is not stored", zap.String("kind", string(e.Kind)))
+	}
+}
+
+func (l *Log) store() {
+	for e := range l.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+
+		_, err := l.pool.Exec(ctx,
+			`INSERT INTO audit_log (time, kind, channel, key_prefix, client_ip, action, detail)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			e.Time, string(e.Kind), e.Channel, e.KeyPrefix, e.ClientIP, e.Action, e.Detail,
+		)
+
+		cancel()
+
+		if err != nil {
+			l.logger.Error("unable to store the audit event", zap.String("kind", string(e.Kind)), zap.Error(err))
+		}
+	}
+}
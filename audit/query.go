@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// Filter selects stored events; zero fields are not applied
+type Filter struct {
+	Channel string
+	Kind    Kind
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+// Query returns the stored events matching the filter, newest first
+func (l *Log) Query(ctx context.Context, f Filter) ([]Event, error) {
+	if l == nil || l.pool == nil {
+		return nil, ErrQueryUnavailable
+	}
+
+	var (
+		conditions []string
+		args       []any
+	)
+
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+" $"+strconv.Itoa(len(args)))
+	}
+
+	if len(f.Channel) > 0 {
+		where("channel =", f.Channel)
+	}
+
+	if len(f.Kind) > 0 {
+		where("kind =", string(f.Kind))
+	}
+
+	if !f.From.IsZero() {
+		where("time >=", f.From)
+	}
+
+	if !f.To.IsZero() {
+		where("time <", f.To)
+	}
+
+	if f.Limit <= 0 {
+		f.Limit = DefaultQueryLimit
+	} else if f.Limit > MaxQueryLimit {
+		f.Limit = MaxQueryLimit
+	}
+
+	query := `SELECT time, kind, channel, key_prefix, client_ip, action, detail FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, f.Limit)
+	query += " ORDER BY time DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := l.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := make([]Event, 0)
+
+	for rows.Next() {
+		var (
+			e    Event
+			kind string
+		)
+
+		if err := rows.Scan(&e.Time, &kind, &e.Channel, &e.KeyPrefix, &e.ClientIP, &e.Action, &e.Detail); err != nil {
+			return nil, err
+		}
+
+		e.Kind = Kind(kind)
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -1,11 +1,25 @@
 package authenticator
 
-import "github.com/go-redis/redis/v8"
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"limq/broker"
+)
 
+// A is the Redis-backed Authenticator
 type A struct {
 	c *redis.Client
+
+	// optional caches, see EnableCache
+	descriptors *ttlCache[Descriptor]
+	forwards    *ttlCache[[]broker.ForwardRule]
 }
 
 func NewA(client *redis.Client) *A {
-	return &A{client}
+	return &A{c: client}
+}
+
+// Ping checks the Redis connection
+func (a *A) Ping(ctx context.Context) error {
+	return a.c.Ping(ctx).Err()
 }
@@ -0,0 +1,518 @@
+package authenticator
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"limq/common"
+	"limq/listeners"
+	"limq/quota"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TagLength is the only channel tag length accepted by the mixin machinery
+const TagLength = 16
+
+const accessMask = AccessRead | AccessWrite | AccessInfoEnabled | AccessSuspended
+
+var (
+	ErrInvalidTag         = errors.New("channel tag must be 16 alphanumeric characters")
+	ErrInvalidPermissions = errors.New("unknown permission bits")
+	ErrInvalidLimits      = errors.New("rate limits must be finite numbers")
+	ErrInvalidPolicy      = errors.New("listener policy must be exclusive, takeover or shared:N")
+	ErrKeyNotFound        = errors.New("access key not found")
+	ErrSelfForward        = errors.New("channel can't forward to itself")
+	ErrForwardCycle       = errors.New("forwarding would form a cycle")
+)
+
+// KeyInfo is an administrative view of an access key
+type KeyInfo struct {
+	Key         string      `json:"key"`
+	Tag         string      `json:"channel_id"`
+	Permissions AccessLevel `json:"permissions"`
+	Origins     []string    `json:"origins,omitempty"`
+	Grants      []Grant     `json:"grants,omitempty"`
+
+	// Limits and ListenerPolicy override the server defaults, zero values keep them
+	Limits         quota.Limits     `json:"limits"`
+	ListenerPolicy listeners.Policy `json:"listener_policy"`
+
+	// DeprecatedUntil is the unix time a rotated key stops working at
+	DeprecatedUntil int64 `json:"deprecated_until,omitempty"`
+}
+
+func ValidateTag(tag string) error {
+	if len(tag) != TagLength {
+		return ErrInvalidTag
+	}
+
+	for _, c := range tag {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return ErrInvalidTag
+		}
+	}
+
+	return nil
+}
+
+func validatePermissions(al AccessLevel) error {
+	if al&^accessMask != 0 || al < 0 {
+		return ErrInvalidPermissions
+	}
+
+	return nil
+}
+
+func validateLimits(l quota.Limits) error {
+	for _, v := range []float64{l.MessagesPerSecond, l.BytesPerSecond} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return ErrInvalidLimits
+		}
+	}
+
+	return nil
+}
+
+func validatePolicy(p listeners.Policy) error {
+	if parsed, err := listeners.ParsePolicy(p.String()); err != nil || parsed != p {
+		return ErrInvalidPolicy
+	}
+
+	return nil
+}
+
+// limitFields are the hash fields of the limits and the listener policy, empty for the ones not set
+func limitFields(l quota.Limits, p listeners.Policy) map[string]string {
+	formatFloat := func(v float64) string {
+		if v == 0 {
+			return ""
+		}
+
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+
+	concurrent := ""
+	if l.Concurrent != 0 {
+		concurrent = strconv.Itoa(l.Concurrent)
+	}
+
+	return map[string]string{
+		rateMessagesRedisKey:   formatFloat(l.MessagesPerSecond),
+		rateBytesRedisKey:      formatFloat(l.BytesPerSecond),
+		maxConcurrentRedisKey:  concurrent,
+		listenerPolicyRedisKey: p.String(),
+	}
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+
+	return b, err
+}
+
+func generateTag() (string, error) {
+	b, err := randomBytes(TagLength / 2)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func generateKey() (string, error) {
+	b, err := randomBytes(24)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// errTagTaken makes CreateChannel retry with another random tag
+var errTagTaken = errors.New("channel tag is taken")
+
+// createChannelRetries bounds the attempts of a channel creation racing other ones
+const createChannelRetries = 5
+
+// CreateChannel registers a new channel with a random tag along with the keys of specs, whose Tag is ignored.
+// Either the channel is created with all of its keys or nothing is
+func (a *A) CreateChannel(ctx context.Context, specs []KeyInfo) (string, []KeyInfo, error) {
+	for _, spec := range specs {
+		if err := ValidateKeySpec(spec); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var (
+		tag  string
+		keys []KeyInfo
+		err  error
+	)
+
+	create := func(tx *redis.Tx) error {
+		taken, err := tx.SIsMember(ctx, common.Channels, tag).Result()
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return errTagTaken
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.SAdd(ctx, common.Channels, tag)
+
+			for _, k := range keys {
+				p.HSet(ctx, common.ChannelDescriptor+k.Key, keyFields(k))
+				p.SAdd(ctx, common.ChannelKeys+tag, k.Key)
+			}
+
+			return nil
+		})
+
+		return err
+	}
+
+	for i := 0; i < createChannelRetries; i++ {
+		tag, err = generateTag()
+		if err != nil {
+			return "", nil, err
+		}
+
+		keys = make([]KeyInfo, len(specs))
+
+		for j, spec := range specs {
+			spec.Tag = tag
+
+			if spec.Key, err = generateKey(); err != nil {
+				return "", nil, err
+			}
+
+			keys[j] = spec
+		}
+
+		err = a.c.Watch(ctx, create, common.Channels)
+		if err != redis.TxFailedErr && err != errTagTaken {
+			break
+		}
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	// the keys could have been cached as invalid ones
+	for _, k := range keys {
+		a.invalidateKey(ctx, k.Key)
+	}
+
+	return tag, keys, nil
+}
+
+// ListChannels returns the channels created through the admin API
+func (a *A) ListChannels(ctx context.Context) ([]string, error) {
+	return a.c.SMembers(ctx, common.Channels).Result()
+}
+
+// ValidateKeySpec checks the fields of a key to be issued, except for its Tag
+func ValidateKeySpec(spec KeyInfo) error {
+	if err := validatePermissions(spec.Permissions); err != nil {
+		return err
+	}
+
+	if err := validateGrants(spec.Grants); err != nil {
+		return err
+	}
+
+	if err := validateLimits(spec.Limits); err != nil {
+		return err
+	}
+
+	return validatePolicy(spec.ListenerPolicy)
+}
+
+// keyFields are the hash fields of a key to be issued
+func keyFields(spec KeyInfo) map[string]any {
+	fields := map[string]any{
+		tagRedisKey:  spec.Tag,
+		permRedisKey: strconv.Itoa(int(spec.Permissions)),
+	}
+
+	if len(spec.Origins) > 0 {
+		fields[originsRedisKey] = strings.Join(spec.Origins, ",")
+	}
+
+	if len(spec.Grants) > 0 {
+		fields[grantsRedisKey] = formatGrants(spec.Grants)
+	}
+
+	for name, value := range limitFields(spec.Limits, spec.ListenerPolicy) {
+		if len(value) > 0 {
+			fields[name] = value
+		}
+	}
+
+	return fields
+}
+
+// CreateKey issues a new access key for the spec.Tag channel; spec.Key is ignored
+func (a *A) CreateKey(ctx context.Context, spec KeyInfo) (KeyInfo, error) {
+	tag := spec.Tag
+
+	if err := ValidateTag(tag); err != nil {
+		return KeyInfo{}, err
+	}
+
+	if err := ValidateKeySpec(spec); err != nil {
+		return KeyInfo{}, err
+	}
+
+	key, err := generateKey()
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	spec.Key = key
+
+	_, err = a.c.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, common.ChannelDescriptor+key, keyFields(spec))
+		p.SAdd(ctx, common.ChannelKeys+tag, key)
+		p.SAdd(ctx, common.Channels, tag)
+
+		return nil
+	})
+
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	// the key could have been cached as an invalid one
+	a.invalidateKey(ctx, key)
+
+	return spec, nil
+}
+
+func keyInfo(key string, fields map[string]string) KeyInfo {
+	info := KeyInfo{
+		Key:         key,
+		Tag:         fields[tagRedisKey],
+		Permissions: parseAccessLevel(fields[permRedisKey]),
+		Origins:     parseList(fields[originsRedisKey]),
+		Grants:      parseGrants(fields[grantsRedisKey]),
+
+		Limits:         parseLimits(fields),
+		ListenerPolicy: parseListenerPolicy(fields[listenerPolicyRedisKey]),
+	}
+
+	if until := parseDeprecation(fields); !until.IsZero() {
+		info.DeprecatedUntil = until.Unix()
+	}
+
+	return info
+}
+
+// ListKeys returns the channel's access keys issued through the admin API
+func (a *A) ListKeys(ctx context.Context, tag string) ([]KeyInfo, error) {
+	if err := ValidateTag(tag); err != nil {
+		return nil, err
+	}
+
+	keys, err := a.c.SMembers(ctx, common.ChannelKeys+tag).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.StringStringMapCmd, len(keys))
+
+	_, err = a.c.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = p.HGetAll(ctx, common.ChannelDescriptor+key)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]KeyInfo, 0, len(keys))
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 || fields[tagRedisKey] != tag {
+			// deleted by hand or moved, drop the stale index entry
+			a.c.SRem(ctx, common.ChannelKeys+tag, keys[i])
+			continue
+		}
+
+		infos = append(infos, keyInfo(keys[i], fields))
+	}
+
+	return infos, nil
+}
+
+// GetKey returns the administrative view of an access key
+func (a *A) GetKey(ctx context.Context, key string) (KeyInfo, error) {
+	fields, err := a.c.HGetAll(ctx, common.ChannelDescriptor+key).Result()
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	if len(fields) == 0 {
+		return KeyInfo{}, ErrKeyNotFound
+	}
+
+	return keyInfo(key, fields), nil
+}
+
+// setLimitsScript replaces the limit fields of an existing key, the empty ones are removed
+var setLimitsScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+
+for i = 1, #ARGV, 2 do
+	if ARGV[i + 1] == '' then
+		redis.call('HDEL', KEYS[1], ARGV[i])
+	else
+		redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	end
+end
+
+return 1
+`)
+
+// SetLimits replaces the rate limits and the listener policy of an access key, zero values restore the defaults
+func (a *A) SetLimits(ctx context.Context, key string, limits quota.Limits, policy listeners.Policy) (KeyInfo, error) {
+	if err := validateLimits(limits); err != nil {
+		return KeyInfo{}, err
+	}
+
+	if err := validatePolicy(policy); err != nil {
+		return KeyInfo{}, err
+	}
+
+	var args []any
+	for name, value := range limitFields(limits, policy) {
+		args = append(args, name, value)
+	}
+
+	found, err := setLimitsScript.Run(ctx, a.c, []string{common.ChannelDescriptor + key}, args...).Int()
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	if found == 0 {
+		return KeyInfo{}, ErrKeyNotFound
+	}
+
+	a.invalidateKey(ctx, key)
+
+	return a.GetKey(ctx, key)
+}
+
+// setSuspendedScript atomically flips the AccessSuspended bit of an existing key
+var setSuspendedScript = redis.NewScript(`
+local perm = redis.call('HGET', KEYS[1], 'permissions')
+if not perm then
+	return -1
+end
+
+perm = tonumber(perm) or 0
+local bit = tonumber(ARGV[1])
+local suspended = math.floor(perm / bit) % 2 == 1
+
+if ARGV[2] == '1' and not suspended then
+	perm = perm + bit
+elseif ARGV[2] == '0' and suspended then
+	perm = perm - bit
+end
+
+redis.call('HSET', KEYS[1], 'permissions', perm)
+return perm
+`)
+
+// SetSuspended suspends or resumes an access key
+func (a *A) SetSuspended(ctx context.Context, key string, suspended bool) (KeyInfo, error) {
+	flag := "0"
+	if suspended {
+		flag = "1"
+	}
+
+	perm, err := setSuspendedScript.Run(ctx, a.c, []string{common.ChannelDescriptor + key}, AccessSuspended, flag).Int()
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	if perm < 0 {
+		return KeyInfo{}, ErrKeyNotFound
+	}
+
+	a.invalidateKey(ctx, key)
+
+	return a.GetKey(ctx, key)
+}
+
+// DeleteKey revokes an access key
+func (a *A) DeleteKey(ctx context.Context, key string) error {
+	info, err := a.GetKey(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.c.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, common.ChannelDescriptor+key)
+		p.SRem(ctx, common.ChannelKeys+info.Tag, key)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	a.invalidateKey(ctx, key)
+	return nil
+}
+
+// SetForwardDestinations replaces the mixin targets of the channel; an empty list removes them
+func (a *A) SetForwardDestinations(ctx context.Context, tag string, targets []string) error {
+	if err := ValidateTag(tag); err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		if err := ValidateTag(t); err != nil {
+			return err
+		}
+
+		if t == tag {
+			return ErrSelfForward
+		}
+	}
+
+	if reaches(tag, targets, a.loadForwardRules) {
+		return ErrForwardCycle
+	}
+
+	var err error
+
+	if len(targets) == 0 {
+		err = a.c.Del(ctx, common.ForwardToDescriptor+tag).Err()
+	} else {
+		err = a.c.Set(ctx, common.ForwardToDescriptor+tag, strings.Join(targets, ","), 0).Err()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	a.invalidateTag(ctx, tag)
+	return nil
+}
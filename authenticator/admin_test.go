@@ -0,0 +1,53 @@
+package authenticator
+
+import (
+	"encoding/json"
+	"limq/listeners"
+	"limq/quota"
+	"math"
+	"testing"
+)
+
+func TestLimitFields(t *testing.T) {
+	limits := quota.Limits{MessagesPerSecond: 2.5, BytesPerSecond: -1, Concurrent: 4}
+	policy := listeners.Policy{Mode: listeners.ModeShared, Max: 3}
+
+	fields := limitFields(limits, policy)
+	if fields[rateMessagesRedisKey] != "2.5" || fields[rateBytesRedisKey] != "-1" || fields[listenerPolicyRedisKey] != "shared:3" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+
+	info := keyInfo("key", fields)
+	if info.Limits != limits || info.ListenerPolicy != policy {
+		t.Errorf("the limits don't survive the round trip: %+v", info)
+	}
+
+	for name, value := range limitFields(quota.Limits{}, listeners.Policy{}) {
+		if len(value) > 0 {
+			t.Errorf("%s must be left unset, got %q", name, value)
+		}
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	if err := validateLimits(quota.Limits{MessagesPerSecond: math.Inf(1)}); err != ErrInvalidLimits {
+		t.Errorf("expected invalid limits, got %v", err)
+	}
+
+	if err := validatePolicy(listeners.Policy{Mode: listeners.ModeShared}); err != ErrInvalidPolicy {
+		t.Errorf("expected invalid policy, got %v", err)
+	}
+
+	spec := KeyInfo{}
+	if err := json.Unmarshal([]byte(`{"limits": {"messages_per_second": 5}, "listener_policy": "takeover"}`), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	if spec.Limits.MessagesPerSecond != 5 || validatePolicy(spec.ListenerPolicy) != nil || spec.ListenerPolicy.Mode != listeners.ModeTakeover {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+
+	if err := json.Unmarshal([]byte(`{"listener_policy": "shared:0"}`), &spec); err == nil {
+		t.Error("a malformed listener policy must be rejected")
+	}
+}
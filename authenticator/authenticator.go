@@ -0,0 +1,40 @@
+package authenticator
+
+import (
+	"context"
+	"limq/broker"
+	"limq/listeners"
+	"limq/quota"
+	"time"
+)
+
+// Authenticator resolves access keys into channel descriptors.
+// An unknown key resolves into a zero Descriptor
+type Authenticator interface {
+	CheckAccessKey(key string) Descriptor
+	CreateMixinManager() broker.MixinManager
+}
+
+// TokenMinter issues short-lived signed tokens, see Signed
+type TokenMinter interface {
+	MintToken(d Descriptor, flags AccessLevel, ttl time.Duration) (string, time.Time, error)
+}
+
+// KeyStore is implemented by the backends managed through the admin API
+type KeyStore interface {
+	CreateChannel(ctx context.Context, keys []KeyInfo) (string, []KeyInfo, error)
+	ListChannels(ctx context.Context) ([]string, error)
+
+	CreateKey(ctx context.Context, spec KeyInfo) (KeyInfo, error)
+	GetKey(ctx context.Context, key string) (KeyInfo, error)
+	ListKeys(ctx context.Context, tag string) ([]KeyInfo, error)
+	SetSuspended(ctx context.Context, key string, suspended bool) (KeyInfo, error)
+	SetLimits(ctx context.Context, key string, limits quota.Limits, policy listeners.Policy) (KeyInfo, error)
+	DeleteKey(ctx context.Context, key string) error
+	RotateKey(ctx context.Context, key string, grace time.Duration) (KeyInfo, error)
+
+	GetForwardDestinations(d Descriptor) []string
+	SetForwardDestinations(ctx context.Context, tag string, targets []string) error
+	GetForwardRules(ctx context.Context, tag string) ([]broker.ForwardRule, error)
+	SetForwardRules(ctx context.Context, tag string, rules []broker.ForwardRule) error
+}
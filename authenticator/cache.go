@@ -0,0 +1,84 @@
+package authenticator
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds the cache memory when it's flooded with random keys
+const maxCacheEntries = 1 << 16
+
+type cacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// ttlCache is a minimal mutex-guarded map with per-entry expiration
+type ttlCache[V any] struct {
+	mu      *sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry[V]
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{mu: &sync.Mutex{}, ttl: ttl, entries: map[string]cacheEntry[V]{}}
+}
+
+func (c *ttlCache[V]) get(key string) (v V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return v, false
+	}
+
+	return e.value, true
+}
+
+func (c *ttlCache[V]) set(key string, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if len(c.entries) >= maxCacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+
+		if len(c.entries) >= maxCacheEntries {
+			c.entries = map[string]cacheEntry[V]{}
+		}
+	}
+
+	c.entries[key] = cacheEntry[V]{value: v, expires: now.Add(c.ttl)}
+}
+
+func (c *ttlCache[V]) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// deleteFunc drops the entries matching the predicate
+func (c *ttlCache[V]) deleteFunc(match func(key string, v V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, e := range c.entries {
+		if match(k, e.value) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *ttlCache[V]) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]cacheEntry[V]{}
+}
@@ -0,0 +1,83 @@
+package authenticator
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+// CertIdentity maps the client certificates with the subject (e.g. "CN=billing,O=Acme")
+// or with the SAN (a DNS name, an email address or a URI) onto a descriptor. The key is not used
+type CertIdentity struct {
+	Subject string `json:"subject" yaml:"subject"`
+	SAN     string `json:"san" yaml:"san"`
+
+	StaticKey `yaml:",inline"`
+}
+
+// CertConfig is the layout of a client certificates file
+type CertConfig struct {
+	Clients []CertIdentity `json:"clients" yaml:"clients"`
+}
+
+// CertMap resolves verified mTLS client certificates into descriptors
+type CertMap struct {
+	subjects map[string]Descriptor
+	sans     map[string]Descriptor
+}
+
+// LoadCertMap reads a YAML or JSON (by extension) client certificates file
+func LoadCertMap(path string) (*CertMap, error) {
+	config := CertConfig{}
+	if err := readFile(path, &config); err != nil {
+		return nil, err
+	}
+
+	return NewCertMap(config)
+}
+
+func NewCertMap(config CertConfig) (*CertMap, error) {
+	m := &CertMap{subjects: map[string]Descriptor{}, sans: map[string]Descriptor{}}
+
+	for _, c := range config.Clients {
+		if (len(c.Subject) == 0) == (len(c.SAN) == 0) {
+			return nil, fmt.Errorf("client of channel %q: exactly one of subject and san must be set", c.Tag)
+		}
+
+		d, err := c.descriptor()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(c.Subject) > 0 {
+			m.subjects[c.Subject] = d
+		} else {
+			m.sans[c.SAN] = d
+		}
+	}
+
+	return m, nil
+}
+
+// Identify resolves the certificate by its SANs first, then by the subject.
+// The identity names the matched subject or SAN, an unknown certificate resolves into a zero Descriptor
+func (m *CertMap) Identify(cert *x509.Certificate) (identity string, d Descriptor) {
+	sans := append([]string{}, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+
+	for _, san := range sans {
+		if d, ok := m.sans[san]; ok {
+			return "san:" + san, d
+		}
+	}
+
+	subject := cert.Subject.String()
+	if d, ok := m.subjects[subject]; ok {
+		return "subject:" + subject, d
+	}
+
+	return "", Descriptor{}
+}
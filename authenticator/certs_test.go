@@ -0,0 +1,51 @@
+package authenticator
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+)
+
+func TestCertMapIdentify(t *testing.T) {
+	m, err := NewCertMap(CertConfig{Clients: []CertIdentity{
+		{Subject: "CN=billing,O=Acme", StaticKey: StaticKey{Tag: "0123456789abcdef", Permissions: AccessRead}},
+		{SAN: "spiffe://acme/reports", StaticKey: StaticKey{Tag: "fedcba9876543210", Permissions: AccessWrite}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	billing := &x509.Certificate{Subject: pkix.Name{CommonName: "billing", Organization: []string{"Acme"}}}
+
+	if id, d := m.Identify(billing); id != "subject:CN=billing,O=Acme" || d.Tag != "0123456789abcdef" || d.Flags != AccessRead {
+		t.Errorf("unexpected identity %q %+v", id, d)
+	}
+
+	reports := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "billing", Organization: []string{"Acme"}},
+		URIs:    []*url.URL{{Scheme: "spiffe", Host: "acme", Path: "/reports"}},
+	}
+
+	if id, d := m.Identify(reports); id != "san:spiffe://acme/reports" || d.Tag != "fedcba9876543210" {
+		t.Errorf("SAN must take precedence over the subject, got %q %+v", id, d)
+	}
+
+	if id, d := m.Identify(&x509.Certificate{DNSNames: []string{"unknown.acme"}}); id != "" || d.Tag != "" {
+		t.Errorf("unknown certificate is identified as %q %+v", id, d)
+	}
+}
+
+func TestCertMapInvalid(t *testing.T) {
+	cases := []CertIdentity{
+		{StaticKey: StaticKey{Tag: "0123456789abcdef", Permissions: AccessRead}},
+		{Subject: "CN=a", SAN: "a", StaticKey: StaticKey{Tag: "0123456789abcdef", Permissions: AccessRead}},
+		{Subject: "CN=a", StaticKey: StaticKey{Tag: "short", Permissions: AccessRead}},
+	}
+
+	for _, c := range cases {
+		if _, err := NewCertMap(CertConfig{Clients: []CertIdentity{c}}); err == nil {
+			t.Errorf("%+v must be rejected", c)
+		}
+	}
+}
@@ -2,20 +2,62 @@ package authenticator
 
 import (
 	"context"
+	"go.uber.org/zap"
 	"limq/common"
+	"limq/listeners"
+	"limq/metrics"
+	"limq/quota"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	permRedisKey = `permissions`
-	tagRedisKey  = `channel_id`
+	permRedisKey    = `permissions`
+	tagRedisKey     = `channel_id`
+	originsRedisKey = `origins`
+	grantsRedisKey  = `grants`
+
+	rateMessagesRedisKey  = `rate_messages`
+	rateBytesRedisKey     = `rate_bytes`
+	maxConcurrentRedisKey = `max_concurrent`
+
+	listenerPolicyRedisKey = `listener_policy`
 )
 
 type Descriptor struct {
 	Tag   string
 	Flags AccessLevel
+
+	// Origins optionally narrows the browser origins allowed for the channel
+	Origins []string
+
+	// Extra grants access to channels besides the primary one, see Channel
+	Extra []Grant
+
+	// Limits override the default rate limits of the key
+	Limits quota.Limits
+
+	// DeprecatedUntil is set for the keys being rotated out, see A.RotateKey
+	DeprecatedUntil time.Time
+
+	// Listeners overrides the default concurrent listeners policy of the key
+	Listeners listeners.Policy
+
+	// Owner identifies the access key a token is minted from, the token shares the key's limits and listener leases.
+	// It is empty for the access keys themselves
+	Owner string
 }
 
 func (a *A) CheckAccessKey(key string) Descriptor {
+	if a.cacheEnabled() {
+		if d, ok := a.descriptors.get(key); ok {
+			return d
+		}
+	}
+
+	defer metrics.AuthLatency.Since(time.Now(), "redis")
+
 	hash := common.ChannelDescriptor + key
 
 	response := a.c.HGetAll(context.Background(), hash)
@@ -25,7 +67,49 @@ func (a *A) CheckAccessKey(key string) Descriptor {
 	}
 
 	d := Descriptor{Tag: result[tagRedisKey],
-		Flags: parseAccessLevel(result[permRedisKey])}
+		Flags:   parseAccessLevel(result[permRedisKey]),
+		Origins: parseList(result[originsRedisKey]),
+		Extra:   parseGrants(result[grantsRedisKey]),
+		Limits:  parseLimits(result),
+
+		DeprecatedUntil: parseDeprecation(result),
+		Listeners:       parseListenerPolicy(result[listenerPolicyRedisKey])}
+
+	if a.cacheEnabled() {
+		a.descriptors.set(key, d)
+	}
 
 	return d
 }
+
+func parseList(raw string) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	values := strings.Split(raw, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+
+	return values
+}
+
+func parseLimits(fields map[string]string) quota.Limits {
+	l := quota.Limits{}
+
+	l.MessagesPerSecond, _ = strconv.ParseFloat(fields[rateMessagesRedisKey], 64)
+	l.BytesPerSecond, _ = strconv.ParseFloat(fields[rateBytesRedisKey], 64)
+	l.Concurrent, _ = strconv.Atoi(fields[maxConcurrentRedisKey])
+
+	return l
+}
+
+func parseListenerPolicy(raw string) listeners.Policy {
+	p, err := listeners.ParsePolicy(raw)
+	if err != nil {
+		zap.L().Warn("invalid listener policy, the default one is used", zap.Error(err))
+	}
+
+	return p
+}
@@ -0,0 +1,84 @@
+package authenticator
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Grant is an access to a single channel
+type Grant struct {
+	Tag   string      `json:"channel_id" yaml:"channel_id"`
+	Flags AccessLevel `json:"permissions" yaml:"permissions"`
+}
+
+// Grants returns every channel the descriptor grants access to, the primary channel first
+func (d Descriptor) Grants() []Grant {
+	grants := make([]Grant, 0, 1+len(d.Extra))
+
+	if len(d.Tag) > 0 {
+		grants = append(grants, Grant{Tag: d.Tag, Flags: d.Flags &^ AccessSuspended})
+	}
+
+	return append(grants, d.Extra...)
+}
+
+// Channel narrows the descriptor down to a single granted channel.
+// Suspension of the key applies to all of its channels
+func (d Descriptor) Channel(tag string) (Descriptor, bool) {
+	if len(tag) == 0 || tag == d.Tag {
+		d.Extra = nil
+		return d, len(d.Tag) > 0
+	}
+
+	for _, g := range d.Extra {
+		if g.Tag == tag {
+			d.Tag = g.Tag
+			d.Flags = g.Flags&^AccessSuspended | d.Flags&AccessSuspended
+			d.Extra = nil
+
+			return d, true
+		}
+	}
+
+	return Descriptor{}, false
+}
+
+// parseGrants parses the "tag:permissions,tag:permissions" form; malformed entries are skipped
+func parseGrants(raw string) []Grant {
+	var grants []Grant
+
+	for _, entry := range parseList(raw) {
+		tag, perm, ok := strings.Cut(entry, ":")
+		if !ok || ValidateTag(tag) != nil {
+			continue
+		}
+
+		grants = append(grants, Grant{Tag: tag, Flags: parseAccessLevel(perm)})
+	}
+
+	return grants
+}
+
+func formatGrants(grants []Grant) string {
+	entries := make([]string, len(grants))
+
+	for i, g := range grants {
+		entries[i] = g.Tag + ":" + strconv.Itoa(int(g.Flags))
+	}
+
+	return strings.Join(entries, ",")
+}
+
+func validateGrants(grants []Grant) error {
+	for _, g := range grants {
+		if err := ValidateTag(g.Tag); err != nil {
+			return err
+		}
+
+		if err := validatePermissions(g.Flags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
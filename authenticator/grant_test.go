@@ -0,0 +1,32 @@
+package authenticator
+
+import "testing"
+
+func TestDescriptorChannel(t *testing.T) {
+	d := Descriptor{
+		Tag:   "0123456789abcdef",
+		Flags: AccessRead | AccessSuspended,
+		Extra: parseGrants("fedcba9876543210:2, bad:1,aaaaaaaaaaaaaaaa:x"),
+	}
+
+	if len(d.Extra) != 2 {
+		t.Fatalf("unexpected grants %+v", d.Extra)
+	}
+
+	c, ok := d.Channel("fedcba9876543210")
+	if !ok || !c.Flags.CanPublish() || c.Flags.CanListen() {
+		t.Errorf("unexpected channel descriptor %+v", c)
+	}
+
+	if c.Flags.Active() {
+		t.Error("key suspension is not applied to extra grants")
+	}
+
+	if _, ok := d.Channel("1111111111111111"); ok {
+		t.Error("channel without a grant is accessible")
+	}
+
+	if c, _ := d.Channel(""); c.Tag != d.Tag {
+		t.Error("empty tag doesn't resolve into the primary channel")
+	}
+}
@@ -0,0 +1,143 @@
+package authenticator
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"limq/broker"
+	"limq/common"
+	"strings"
+	"time"
+)
+
+const (
+	invalidateKeyPrefix = "key:"
+	invalidateTagPrefix = "tag:"
+	invalidateAll       = "*"
+)
+
+// EnableCache turns on the in-process cache of descriptors and forward lists.
+// Entries live for at most ttl; changes made through the admin API or announced on
+// the common.InvalidationChannel (and keyspace notifications, if enabled on the server)
+// drop them earlier. The watcher stops with ctx
+func (a *A) EnableCache(ctx context.Context, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	a.descriptors = newTTLCache[Descriptor](ttl)
+	a.forwards = newTTLCache[[]broker.ForwardRule](ttl)
+
+	go a.watchInvalidations(ctx)
+}
+
+func (a *A) cacheEnabled() bool {
+	return a.descriptors != nil
+}
+
+// invalidate drops local cache entries and notifies the other replicas
+func (a *A) invalidate(ctx context.Context, message string) {
+	a.dropCached(message)
+
+	if err := a.c.Publish(ctx, common.InvalidationChannel, message).Err(); err != nil {
+		zap.L().Warn("unable to publish cache invalidation", zap.String("message", message), zap.Error(err))
+	}
+}
+
+func (a *A) invalidateKey(ctx context.Context, key string) {
+	a.invalidate(ctx, invalidateKeyPrefix+key)
+}
+
+func (a *A) invalidateTag(ctx context.Context, tag string) {
+	a.invalidate(ctx, invalidateTagPrefix+tag)
+}
+
+func (a *A) dropCached(message string) {
+	if !a.cacheEnabled() {
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(message, invalidateKeyPrefix):
+		a.descriptors.delete(strings.TrimPrefix(message, invalidateKeyPrefix))
+
+	case strings.HasPrefix(message, invalidateTagPrefix):
+		tag := strings.TrimPrefix(message, invalidateTagPrefix)
+
+		a.forwards.delete(tag)
+		a.descriptors.deleteFunc(func(_ string, d Descriptor) bool {
+			return d.Tag == tag
+		})
+
+	default:
+		a.descriptors.purge()
+		a.forwards.purge()
+	}
+}
+
+// keyspaceMessage translates a keyspace notification channel into an invalidation message
+func keyspaceMessage(channel string) string {
+	_, key, ok := strings.Cut(channel, "__:")
+	if !ok {
+		return invalidateAll
+	}
+
+	switch {
+	case strings.HasPrefix(key, common.ChannelDescriptor):
+		return invalidateKeyPrefix + strings.TrimPrefix(key, common.ChannelDescriptor)
+
+	case strings.HasPrefix(key, common.ForwardToDescriptor):
+		return invalidateTagPrefix + strings.TrimPrefix(key, common.ForwardToDescriptor)
+
+	case strings.HasPrefix(key, common.ForwardRules):
+		return invalidateTagPrefix + strings.TrimPrefix(key, common.ForwardRules)
+
+	default:
+		return invalidateAll
+	}
+}
+
+func (a *A) watchInvalidations(ctx context.Context) {
+	keyspace := fmt.Sprintf("__keyspace@%d__:", a.c.Options().DB)
+
+	ps := a.c.Subscribe(ctx, common.InvalidationChannel)
+	defer ps.Close()
+
+	err := ps.PSubscribe(ctx,
+		keyspace+common.ChannelDescriptor+"*",
+		keyspace+common.ForwardToDescriptor+"*",
+		keyspace+common.ForwardRules+"*",
+	)
+	if err != nil {
+		zap.L().Warn("unable to subscribe to keyspace notifications", zap.Error(err))
+	}
+
+	for {
+		msg, err := ps.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			// messages could be lost while reconnecting
+			a.dropCached(invalidateAll)
+			time.Sleep(time.Second)
+
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			// (re)subscribed, anything could have changed in between
+			a.dropCached(invalidateAll)
+
+		case *redis.Message:
+			if m.Channel == common.InvalidationChannel {
+				a.dropCached(m.Payload)
+			} else {
+				a.dropCached(keyspaceMessage(m.Channel))
+			}
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package authenticator
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"limq/broker"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWT claims carrying the channel access
+const (
+	ClaimChannel     = "limq_channel"
+	ClaimPermissions = "limq_permissions"
+	ClaimOrigins     = "limq_origins"
+	ClaimGrants      = "limq_grants"
+)
+
+var (
+	ErrJWTMalformed   = errors.New("jwt is malformed")
+	ErrJWTUnknownKey  = errors.New("jwt is signed with an unknown key")
+	ErrJWTBadClaims   = errors.New("jwt claims are invalid or expired")
+	ErrJWTUnsupported = errors.New("jwt algorithm is not supported")
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer      string          `json:"iss"`
+	Audience    json.RawMessage `json:"aud"`
+	Expires     int64           `json:"exp"`
+	NotBefore   int64           `json:"nbf"`
+	Channel     string          `json:"limq_channel"`
+	Permissions AccessLevel     `json:"limq_permissions"`
+	Origins     []string        `json:"limq_origins"`
+	Grants      []Grant         `json:"limq_grants"`
+}
+
+// JWT is an Authenticator verifying RS* and ES* signed JWTs against a JWKS file.
+// Mixins are not supported by this backend
+type JWT struct {
+	keys     map[string]crypto.PublicKey
+	issuer   string
+	audience string
+}
+
+// LoadJWT reads the JWKS file. Empty issuer or audience are not checked
+func LoadJWT(jwksPath, issuer, audience string) (*JWT, error) {
+	raw, err := os.ReadFile(jwksPath)
+	if err != nil {
+		return nil, err
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("%s: %w", jwksPath, err)
+	}
+
+	j := &JWT{keys: map[string]crypto.PublicKey{}, issuer: issuer, audience: audience}
+
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", jwksPath, k.Kid, err)
+		}
+
+		j.keys[k.Kid] = pub
+	}
+
+	return j, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrJWTUnsupported
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, ErrJWTUnsupported
+	}
+}
+
+func jwtHash(alg string) (crypto.Hash, bool) {
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, true
+	case "384":
+		return crypto.SHA384, true
+	case "512":
+		return crypto.SHA512, true
+	default:
+		return 0, false
+	}
+}
+
+func (j *JWT) verifySignature(header jwtHeader, signed string, signature []byte) error {
+	pub, ok := j.keys[header.Kid]
+	if !ok {
+		return ErrJWTUnknownKey
+	}
+
+	if len(header.Alg) != 5 {
+		return ErrJWTUnsupported
+	}
+
+	hash, ok := jwtHash(header.Alg)
+	if !ok {
+		return ErrJWTUnsupported
+	}
+
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if header.Alg[:2] != "RS" {
+			return ErrJWTUnsupported
+		}
+
+		if rsa.VerifyPKCS1v15(key, hash, digest, signature) != nil {
+			return ErrJWTMalformed
+		}
+
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if header.Alg[:2] != "ES" || len(signature) != 2*size {
+			return ErrJWTUnsupported
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+
+		if !ecdsa.Verify(key, digest, r, s) {
+			return ErrJWTMalformed
+		}
+
+	default:
+		return ErrJWTUnsupported
+	}
+
+	return nil
+}
+
+func (c jwtClaims) hasAudience(audience string) bool {
+	var single string
+	if json.Unmarshal(c.Audience, &single) == nil {
+		return single == audience
+	}
+
+	var list []string
+	if json.Unmarshal(c.Audience, &list) == nil {
+		for _, a := range list {
+			if a == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (j *JWT) parse(token string, now time.Time) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtClaims{}, ErrJWTMalformed
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return jwtClaims{}, ErrJWTMalformed
+	}
+
+	header := jwtHeader{}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return jwtClaims{}, ErrJWTMalformed
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtClaims{}, ErrJWTMalformed
+	}
+
+	if err := j.verifySignature(header, parts[0]+"."+parts[1], signature); err != nil {
+		return jwtClaims{}, err
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return jwtClaims{}, ErrJWTMalformed
+	}
+
+	claims := jwtClaims{}
+	if err := json.Unmarshal(rawClaims, &claims); err != nil {
+		return jwtClaims{}, ErrJWTMalformed
+	}
+
+	switch {
+	case claims.Expires == 0 || now.Unix() >= claims.Expires,
+		claims.NotBefore != 0 && now.Unix() < claims.NotBefore,
+		len(j.issuer) > 0 && claims.Issuer != j.issuer,
+		len(j.audience) > 0 && !claims.hasAudience(j.audience),
+		ValidateTag(claims.Channel) != nil,
+		validateGrants(claims.Grants) != nil:
+		return jwtClaims{}, ErrJWTBadClaims
+	}
+
+	return claims, nil
+}
+
+func (j *JWT) CheckAccessKey(key string) Descriptor {
+	claims, err := j.parse(key, time.Now())
+	if err != nil {
+		return Descriptor{}
+	}
+
+	return Descriptor{Tag: claims.Channel, Flags: claims.Permissions, Origins: claims.Origins, Extra: claims.Grants}
+}
+
+func (j *JWT) GetRules(string) []broker.ForwardRule {
+	return nil
+}
+
+func (j *JWT) CreateMixinManager() broker.MixinManager {
+	return j
+}
@@ -0,0 +1,68 @@
+package authenticator
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := &JWT{keys: map[string]crypto.PublicKey{"k1": &key.PublicKey}, audience: "limq"}
+
+	claims := map[string]any{
+		ClaimChannel:     "0123456789abcdef",
+		ClaimPermissions: AccessRead,
+		"aud":            []string{"other", "limq"},
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	}
+
+	d := j.CheckAccessKey(signES256(t, key, "k1", claims))
+	if d.Tag != "0123456789abcdef" || d.Flags != AccessRead {
+		t.Errorf("unexpected descriptor %+v", d)
+	}
+
+	if d := j.CheckAccessKey(signES256(t, key, "k2", claims)); d.Tag != "" {
+		t.Error("token signed with an unknown kid is accepted")
+	}
+
+	claims["aud"] = "other"
+	if d := j.CheckAccessKey(signES256(t, key, "k1", claims)); d.Tag != "" {
+		t.Error("token for another audience is accepted")
+	}
+
+	claims["aud"] = "limq"
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	if d := j.CheckAccessKey(signES256(t, key, "k1", claims)); d.Tag != "" {
+		t.Error("expired token is accepted")
+	}
+}
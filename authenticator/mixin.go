@@ -2,6 +2,7 @@ package authenticator
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
@@ -25,6 +26,10 @@ func (a *A) GetForwardDestinations(d Descriptor) []string {
 		}
 	}
 
+	return parseDestinations(list)
+}
+
+func parseDestinations(list string) []string {
 	storedValues := strings.Split(list, ",")
 	values := make([]string, 0, len(storedValues))
 
@@ -37,12 +42,77 @@ func (a *A) GetForwardDestinations(d Descriptor) []string {
 	return values
 }
 
+// GetForwardRules returns the structured forwarding rules of the channel, without the legacy destinations
+func (a *A) GetForwardRules(ctx context.Context, tag string) ([]broker.ForwardRule, error) {
+	raw, err := a.c.Get(ctx, common.ForwardRules+tag).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return []broker.ForwardRule{}, nil
+		}
+
+		return nil, err
+	}
+
+	rules := []broker.ForwardRule{}
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// forwardRules returns the structured rules followed by the legacy destinations of the channel
+func (a *A) forwardRules(tag string) []broker.ForwardRule {
+	if a.cacheEnabled() {
+		if rules, ok := a.forwards.get(tag); ok {
+			return rules
+		}
+	}
+
+	rules := a.loadForwardRules(tag)
+
+	if a.cacheEnabled() {
+		a.forwards.set(tag, rules)
+	}
+
+	return rules
+}
+
+func (a *A) loadForwardRules(tag string) []broker.ForwardRule {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	var structured, legacy *redis.StringCmd
+
+	_, err := a.c.Pipelined(ctx, func(p redis.Pipeliner) error {
+		structured = p.Get(ctx, common.ForwardRules+tag)
+		legacy = p.Get(ctx, common.ForwardToDescriptor+tag)
+
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, redis.Nil) {
+		zap.L().Warn("redis error obtaining forwarding rules", zap.String("chan_id", tag), zap.Error(err))
+		return nil
+	}
+
+	var rules []broker.ForwardRule
+
+	if raw, err := structured.Bytes(); err == nil {
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			zap.L().Warn("malformed forwarding rules", zap.String("chan_id", tag), zap.Error(err))
+		}
+	}
+
+	return append(rules, broker.PlainRules(parseDestinations(legacy.Val()))...)
+}
+
 type mmanImplement struct {
 	a *A
 }
 
-func (m *mmanImplement) GetForwards(tag string) []string {
-	return m.a.GetForwardDestinations(Descriptor{Tag: tag})
+func (m *mmanImplement) GetRules(tag string) []broker.ForwardRule {
+	return m.a.forwardRules(tag)
 }
 
 func (a *A) CreateMixinManager() broker.MixinManager {
@@ -0,0 +1,134 @@
+package authenticator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.uber.org/zap"
+	"limq/broker"
+	"limq/metrics"
+	"limq/storage"
+	"time"
+)
+
+// PostgresSchema creates the tables read by the Postgres authenticator
+const PostgresSchema = `
+CREATE TABLE IF NOT EXISTS access_keys (
+	key         TEXT PRIMARY KEY,
+	tag         CHAR(16) NOT NULL,
+	permissions INTEGER NOT NULL DEFAULT 0,
+	origins     TEXT NOT NULL DEFAULT '',
+	grants      TEXT NOT NULL DEFAULT ''
+);
+
+ALTER TABLE access_keys ADD COLUMN IF NOT EXISTS grants TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS access_keys_tag ON access_keys (tag);
+
+CREATE TABLE IF NOT EXISTS channel_forwards (
+	tag    CHAR(16) NOT NULL,
+	target CHAR(16) NOT NULL,
+	PRIMARY KEY (tag, target)
+);
+
+CREATE TABLE IF NOT EXISTS channel_forward_rules (
+	tag   CHAR(16) PRIMARY KEY,
+	rules JSONB NOT NULL
+);
+`
+
+// Postgres is an Authenticator reading descriptors from the access_keys table
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgres creates the authenticator tables if necessary
+func NewPostgres(ctx context.Context, pool *pgxpool.Pool) (*Postgres, error) {
+	if _, err := pool.Exec(ctx, PostgresSchema); err != nil {
+		return nil, err
+	}
+
+	return &Postgres{pool: pool}, nil
+}
+
+func (p *Postgres) CheckAccessKey(key string) Descriptor {
+	defer metrics.AuthLatency.Since(time.Now(), "postgres")
+
+	ctx, cancel := context.WithTimeout(context.Background(), storage.DBTimeout)
+	defer cancel()
+
+	var (
+		d           Descriptor
+		permissions int32
+		origins     string
+		grants      string
+	)
+
+	err := p.pool.QueryRow(ctx, `SELECT tag, permissions, origins, grants FROM access_keys WHERE key = $1`, key).
+		Scan(&d.Tag, &permissions, &origins, &grants)
+
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			zap.L().Warn("pgx error obtaining access key", zap.Error(err))
+		}
+
+		return Descriptor{}
+	}
+
+	d.Flags = AccessLevel(permissions)
+	d.Origins = parseList(origins)
+	d.Extra = parseGrants(grants)
+
+	return d
+}
+
+// GetRules returns the channel_forward_rules rules followed by the channel_forwards targets
+func (p *Postgres) GetRules(tag string) []broker.ForwardRule {
+	ctx, cancel := context.WithTimeout(context.Background(), storage.DBTimeout)
+	defer cancel()
+
+	var rules []broker.ForwardRule
+
+	var raw string
+
+	err := p.pool.QueryRow(ctx, `SELECT rules::text FROM channel_forward_rules WHERE tag = $1`, tag).Scan(&raw)
+	if err == nil {
+		err = json.Unmarshal([]byte(raw), &rules)
+	}
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		zap.L().Warn("unable to obtain forwarding rules", zap.String("chan_id", tag), zap.Error(err))
+	}
+
+	return append(rules, broker.PlainRules(p.forwards(ctx, tag))...)
+}
+
+func (p *Postgres) forwards(ctx context.Context, tag string) []string {
+	rows, err := p.pool.Query(ctx, `SELECT target FROM channel_forwards WHERE tag = $1`, tag)
+	if err != nil {
+		zap.L().Warn("pgx error obtaining forwards", zap.String("chan_id", tag), zap.Error(err))
+		return nil
+	}
+
+	defer rows.Close()
+
+	var targets []string
+
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			zap.L().Warn("pgx error obtaining forwards", zap.String("chan_id", tag), zap.Error(err))
+			return nil
+		}
+
+		targets = append(targets, t)
+	}
+
+	return targets
+}
+
+func (p *Postgres) CreateMixinManager() broker.MixinManager {
+	return p
+}
@@ -0,0 +1,116 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"limq/common"
+	"strconv"
+	"time"
+)
+
+const (
+	deprecatedRedisKey = `deprecated_until`
+	successorRedisKey  = `successor`
+)
+
+const (
+	DefaultRotationGrace = 24 * time.Hour
+	MaxRotationGrace     = 30 * 24 * time.Hour
+)
+
+// rotateRetries bounds the attempts of a rotation racing other changes of the key
+const rotateRetries = 5
+
+var ErrKeyDeprecated = errors.New("access key is already deprecated")
+
+func parseDeprecation(fields map[string]string) time.Time {
+	unix, err := strconv.ParseInt(fields[deprecatedRedisKey], 10, 64)
+	if err != nil || unix <= 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(unix, 0)
+}
+
+// Deprecated reports whether the key is being rotated out
+func (d Descriptor) Deprecated() bool {
+	return !d.DeprecatedUntil.IsZero()
+}
+
+// Expired reports whether the grace period of a rotated key is over
+func (d Descriptor) Expired(now time.Time) bool {
+	return d.Deprecated() && !now.Before(d.DeprecatedUntil)
+}
+
+// RotateKey issues a successor with the same channel and permissions.
+// The old key keeps working for the grace period and is removed by Redis afterwards
+func (a *A) RotateKey(ctx context.Context, key string, grace time.Duration) (KeyInfo, error) {
+	if grace <= 0 {
+		grace = DefaultRotationGrace
+	} else if grace > MaxRotationGrace {
+		grace = MaxRotationGrace
+	}
+
+	hash := common.ChannelDescriptor + key
+
+	successor, err := generateKey()
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	var fields map[string]string
+
+	// the key is watched so that a concurrent rotation, suspension or deletion makes the transaction retry
+	rotate := func(tx *redis.Tx) error {
+		current, err := tx.HGetAll(ctx, hash).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(current) == 0 {
+			return ErrKeyNotFound
+		}
+
+		if len(current[deprecatedRedisKey]) > 0 {
+			return ErrKeyDeprecated
+		}
+
+		fields = current
+
+		until := time.Now().Add(grace).Truncate(time.Second)
+		tag := fields[tagRedisKey]
+
+		copied := make(map[string]any, len(fields))
+		for k, v := range fields {
+			copied[k] = v
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HSet(ctx, common.ChannelDescriptor+successor, copied)
+			p.SAdd(ctx, common.ChannelKeys+tag, successor)
+
+			p.HSet(ctx, hash, deprecatedRedisKey, until.Unix(), successorRedisKey, successor)
+			p.ExpireAt(ctx, hash, until)
+
+			return nil
+		})
+
+		return err
+	}
+
+	for i := 0; i < rotateRetries; i++ {
+		err = a.c.Watch(ctx, rotate, hash)
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	a.invalidateKey(ctx, key)
+
+	return keyInfo(successor, fields), nil
+}
@@ -0,0 +1,120 @@
+package authenticator
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"limq/broker"
+	"limq/common"
+)
+
+// reaches reports whether the tag channel is reachable from the destinations through the rulesOf rules
+func reaches(tag string, destinations []string, rulesOf func(tag string) []broker.ForwardRule) bool {
+	visited := map[string]bool{}
+
+	for len(destinations) > 0 {
+		next := destinations[len(destinations)-1]
+		destinations = destinations[:len(destinations)-1]
+
+		if next == tag {
+			return true
+		}
+
+		if visited[next] {
+			continue
+		}
+
+		visited[next] = true
+
+		for _, r := range rulesOf(next) {
+			destinations = append(destinations, r.Destination)
+		}
+	}
+
+	return false
+}
+
+func destinations(rules []broker.ForwardRule) []string {
+	tags := make([]string, len(rules))
+
+	for i, r := range rules {
+		tags[i] = r.Destination
+	}
+
+	return tags
+}
+
+// validateRules checks the forwarding rules of the tag channel and assigns IDs to the rules lacking them
+func validateRules(tag string, rules []broker.ForwardRule) error {
+	ids := map[string]bool{}
+
+	for i := range rules {
+		r := &rules[i]
+
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		if err := ValidateTag(r.Destination); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		if r.Destination == tag {
+			return ErrSelfForward
+		}
+
+		if len(r.ID) == 0 {
+			b, err := randomBytes(4)
+			if err != nil {
+				return err
+			}
+
+			r.ID = hex.EncodeToString(b)
+		}
+
+		if ids[r.ID] {
+			return fmt.Errorf("rule %d: duplicate id %q: %w", i, r.ID, broker.ErrInvalidRule)
+		}
+
+		ids[r.ID] = true
+	}
+
+	return nil
+}
+
+// SetForwardRules replaces the structured forwarding rules of the channel; an empty list removes them.
+// Rules are evaluated in order, after them the legacy destinations
+func (a *A) SetForwardRules(ctx context.Context, tag string, rules []broker.ForwardRule) error {
+	if err := ValidateTag(tag); err != nil {
+		return err
+	}
+
+	if err := validateRules(tag, rules); err != nil {
+		return err
+	}
+
+	if reaches(tag, destinations(rules), a.loadForwardRules) {
+		return ErrForwardCycle
+	}
+
+	var err error
+
+	if len(rules) == 0 {
+		err = a.c.Del(ctx, common.ForwardRules+tag).Err()
+	} else {
+		var raw []byte
+
+		raw, err = json.Marshal(rules)
+		if err == nil {
+			err = a.c.Set(ctx, common.ForwardRules+tag, raw, 0).Err()
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	a.invalidateTag(ctx, tag)
+	return nil
+}
@@ -0,0 +1,35 @@
+package authenticator
+
+import (
+	"errors"
+	"limq/broker"
+	"testing"
+)
+
+func TestStaticForwardCycle(t *testing.T) {
+	const (
+		a = "aaaaaaaaaaaaaaaa"
+		b = "bbbbbbbbbbbbbbbb"
+		c = "cccccccccccccccc"
+	)
+
+	config := StaticConfig{
+		Forwards: map[string][]string{a: {b}},
+		Rules:    map[string][]broker.ForwardRule{b: {{Destination: c}}},
+	}
+
+	s, err := NewStatic(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rules := s.GetRules(b); len(rules) != 1 || len(rules[0].ID) == 0 {
+		t.Errorf("rule ID is not assigned: %+v", rules)
+	}
+
+	config.Rules[c] = []broker.ForwardRule{{Destination: a, Filter: &broker.ForwardFilter{Types: []string{"text"}}}}
+
+	if _, err := NewStatic(config); !errors.Is(err, ErrForwardCycle) {
+		t.Errorf("cycle is not detected: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package authenticator
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"limq/broker"
+	"limq/listeners"
+	"limq/quota"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StaticKey is an access key entry of a static descriptors file
+type StaticKey struct {
+	Key         string      `json:"key" yaml:"key"`
+	Tag         string      `json:"channel_id" yaml:"channel_id"`
+	Permissions AccessLevel `json:"permissions" yaml:"permissions"`
+	Origins     []string    `json:"origins" yaml:"origins"`
+	Grants      []Grant     `json:"grants" yaml:"grants"`
+
+	Limits    quota.Limits     `json:"limits" yaml:"limits"`
+	Listeners listeners.Policy `json:"listener_policy" yaml:"listener_policy"`
+}
+
+// StaticConfig is the layout of a static descriptors file
+type StaticConfig struct {
+	Keys     []StaticKey         `json:"keys" yaml:"keys"`
+	Forwards map[string][]string `json:"forwards" yaml:"forwards"`
+
+	Rules map[string][]broker.ForwardRule `json:"rules" yaml:"rules"`
+}
+
+// Static is an Authenticator serving descriptors and mixins from a file, for small deployments
+type Static struct {
+	keys  map[string]Descriptor
+	rules map[string][]broker.ForwardRule
+}
+
+// LoadStatic reads a YAML or JSON (by extension) descriptors file
+func LoadStatic(path string) (*Static, error) {
+	config := StaticConfig{}
+	if err := readFile(path, &config); err != nil {
+		return nil, err
+	}
+
+	return NewStatic(config)
+}
+
+// readFile decodes a YAML or JSON (by extension) file into v
+func readFile(path string, v any) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(raw, v)
+
+	default:
+		err = yaml.Unmarshal(raw, v)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+func NewStatic(config StaticConfig) (*Static, error) {
+	s := &Static{keys: map[string]Descriptor{}, rules: map[string][]broker.ForwardRule{}}
+
+	for _, k := range config.Keys {
+		if len(k.Key) == 0 || IsToken(k.Key) {
+			return nil, fmt.Errorf("key of channel %q: must be non-empty and must not start with %q", k.Tag, tokenPrefix)
+		}
+
+		d, err := k.descriptor()
+		if err != nil {
+			return nil, err
+		}
+
+		s.keys[k.Key] = d
+	}
+
+	for tag, rules := range config.Rules {
+		if err := validateRules(tag, rules); err != nil {
+			return nil, fmt.Errorf("rules of channel %q: %w", tag, err)
+		}
+
+		s.rules[tag] = rules
+	}
+
+	for tag, targets := range config.Forwards {
+		for _, t := range targets {
+			if err := ValidateTag(t); err != nil {
+				return nil, fmt.Errorf("forwards of channel %q: %w", tag, err)
+			}
+		}
+
+		s.rules[tag] = append(s.rules[tag], broker.PlainRules(targets)...)
+	}
+
+	for tag, rules := range s.rules {
+		if reaches(tag, destinations(rules), s.GetRules) {
+			return nil, fmt.Errorf("rules of channel %q: %w", tag, ErrForwardCycle)
+		}
+	}
+
+	return s, nil
+}
+
+// descriptor validates the channel, the permissions and the grants of the entry
+func (k StaticKey) descriptor() (Descriptor, error) {
+	if err := ValidateTag(k.Tag); err != nil {
+		return Descriptor{}, fmt.Errorf("key of channel %q: %w", k.Tag, err)
+	}
+
+	if err := validatePermissions(k.Permissions); err != nil {
+		return Descriptor{}, fmt.Errorf("key of channel %q: %w", k.Tag, err)
+	}
+
+	if err := validateGrants(k.Grants); err != nil {
+		return Descriptor{}, fmt.Errorf("grants of channel %q: %w", k.Tag, err)
+	}
+
+	return Descriptor{Tag: k.Tag, Flags: k.Permissions, Origins: k.Origins, Extra: k.Grants,
+		Limits: k.Limits, Listeners: k.Listeners}, nil
+}
+
+func (s *Static) CheckAccessKey(key string) Descriptor {
+	return s.keys[key]
+}
+
+func (s *Static) GetRules(tag string) []broker.ForwardRule {
+	return s.rules[tag]
+}
+
+func (s *Static) CreateMixinManager() broker.MixinManager {
+	return s
+}
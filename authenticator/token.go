@@ -0,0 +1,157 @@
+package authenticator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"limq/listeners"
+	"limq/quota"
+	"strings"
+	"time"
+)
+
+// tokenPrefix distinguishes signed tokens from Redis-backed access keys
+const tokenPrefix = "t."
+
+const (
+	DefaultTokenTTL = 15 * time.Minute
+	MaxTokenTTL     = 24 * time.Hour
+)
+
+var (
+	ErrTokensDisabled      = errors.New("signed tokens are not configured")
+	ErrTokenInvalid        = errors.New("token is malformed or has a bad signature")
+	ErrTokenExpired        = errors.New("token is expired")
+	ErrPermissionsExceeded = errors.New("requested permissions are not held by the access key")
+)
+
+type tokenClaims struct {
+	Tag     string      `json:"tag"`
+	Flags   AccessLevel `json:"perm"`
+	Expires int64       `json:"exp"`
+
+	// Owner, Origins, Limits and Listeners are inherited from the access key the token is minted from
+	Owner     string           `json:"own,omitempty"`
+	Origins   []string         `json:"origins,omitempty"`
+	Limits    quota.Limits     `json:"limits"`
+	Listeners listeners.Policy `json:"listeners"`
+}
+
+var tokenEncoding = base64.RawURLEncoding
+
+// Signed adds offline-verified signed tokens to any Authenticator backend
+type Signed struct {
+	Authenticator
+	secret []byte
+}
+
+// NewSigned wraps the backend; tokens are disabled when secret is empty
+func NewSigned(backend Authenticator, secret []byte) *Signed {
+	return &Signed{Authenticator: backend, secret: secret}
+}
+
+func (s *Signed) CheckAccessKey(key string) Descriptor {
+	if IsToken(key) {
+		return s.checkToken(key)
+	}
+
+	return s.Authenticator.CheckAccessKey(key)
+}
+
+func IsToken(key string) bool {
+	return strings.HasPrefix(key, tokenPrefix)
+}
+
+// MintToken issues a token granting the subset flags of the d channel access until now+ttl.
+// The token keeps the owner, the origins, the limits and the listener policy of d. The token is verified offline by the holder of the same secret
+func (s *Signed) MintToken(d Descriptor, flags AccessLevel, ttl time.Duration) (string, time.Time, error) {
+	if len(s.secret) == 0 {
+		return "", time.Time{}, ErrTokensDisabled
+	}
+
+	flags &^= AccessSuspended
+
+	if flags == 0 || flags&^d.Flags != 0 {
+		return "", time.Time{}, ErrPermissionsExceeded
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	} else if ttl > MaxTokenTTL {
+		ttl = MaxTokenTTL
+	}
+
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+
+	// tokens must not outlive a key being rotated out
+	if d.Deprecated() && d.DeprecatedUntil.Before(expires) {
+		expires = d.DeprecatedUntil
+	}
+
+	payload, err := json.Marshal(tokenClaims{Tag: d.Tag, Flags: flags, Expires: expires.Unix(), Owner: d.Owner, Origins: d.Origins, Limits: d.Limits, Listeners: d.Listeners})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	encoded := tokenEncoding.EncodeToString(payload)
+	token := tokenPrefix + encoded + "." + tokenEncoding.EncodeToString(s.sign(encoded))
+
+	return token, expires, nil
+}
+
+func (s *Signed) sign(encodedClaims string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encodedClaims))
+
+	return mac.Sum(nil)
+}
+
+func (s *Signed) parseToken(token string, now time.Time) (tokenClaims, error) {
+	if len(s.secret) == 0 {
+		return tokenClaims{}, ErrTokensDisabled
+	}
+
+	encoded, signature, ok := strings.Cut(strings.TrimPrefix(token, tokenPrefix), ".")
+	if !ok {
+		return tokenClaims{}, ErrTokenInvalid
+	}
+
+	rawSignature, err := tokenEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(rawSignature, s.sign(encoded)) {
+		return tokenClaims{}, ErrTokenInvalid
+	}
+
+	payload, err := tokenEncoding.DecodeString(encoded)
+	if err != nil {
+		return tokenClaims{}, ErrTokenInvalid
+	}
+
+	claims := tokenClaims{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return tokenClaims{}, ErrTokenInvalid
+	}
+
+	if now.Unix() >= claims.Expires {
+		return tokenClaims{}, ErrTokenExpired
+	}
+
+	return claims, nil
+}
+
+func (s *Signed) checkToken(token string) Descriptor {
+	claims, err := s.parseToken(token, time.Now())
+	if err != nil {
+		return Descriptor{}
+	}
+
+	return Descriptor{
+		Tag:       claims.Tag,
+		Flags:     claims.Flags &^ AccessSuspended,
+		Origins:   claims.Origins,
+		Limits:    claims.Limits,
+		Listeners: claims.Listeners,
+		Owner:     claims.Owner,
+	}
+}
@@ -0,0 +1,50 @@
+package authenticator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	a := NewSigned(nil, []byte("secret"))
+	key := Descriptor{Tag: "0123456789abcdef", Flags: AccessRead | AccessWrite, Origins: []string{"https://a.example"}}
+
+	token, _, err := a.MintToken(key, AccessRead, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsToken(token) {
+		t.Fatal("minted token is not recognized as a token")
+	}
+
+	d := a.CheckAccessKey(token)
+	if d.Tag != key.Tag || d.Flags != AccessRead || len(d.Origins) != 1 || d.Origins[0] != key.Origins[0] {
+		t.Errorf("unexpected descriptor %+v", d)
+	}
+
+	if d := NewSigned(nil, []byte("other")).CheckAccessKey(token); d.Tag != "" {
+		t.Error("token is accepted with a foreign secret")
+	}
+
+	if d := a.CheckAccessKey(token[:len(token)-2]); d.Tag != "" {
+		t.Error("truncated token is accepted")
+	}
+
+	if _, err := a.parseToken(token, time.Now().Add(2*time.Minute)); err != ErrTokenExpired {
+		t.Errorf("expected expiration error, got %v", err)
+	}
+}
+
+func TestTokenPermissionsSubset(t *testing.T) {
+	a := NewSigned(nil, []byte("secret"))
+	key := Descriptor{Tag: "0123456789abcdef", Flags: AccessRead}
+
+	if _, _, err := a.MintToken(key, AccessWrite, time.Minute); err != ErrPermissionsExceeded {
+		t.Errorf("expected permissions error, got %v", err)
+	}
+
+	if _, _, err := NewSigned(nil, nil).MintToken(key, AccessRead, time.Minute); err != ErrTokensDisabled {
+		t.Errorf("expected disabled error, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package broker
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"limq/message"
+	"time"
+)
+
+const drainPollInterval = 50 * time.Millisecond
+
+// Draining is closed once Drain begins, the listeners must leave then
+func (aq *Mega) Draining() <-chan struct{} {
+	return aq.draining
+}
+
+func (aq *Mega) isDraining() bool {
+	select {
+	case <-aq.draining:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// Requeue buffers a message taken from a stream but not delivered, e.g. by a listener leaving on drain
+func (aq *Mega) Requeue(m *message.Message) {
+	if err := aq.store.Put(m); err != nil {
+		zap.L().Error("unable to requeue an undelivered message", zap.String("chan_id", m.ChannelID), zap.Error(err))
+	}
+}
+
+// BeginDrain stops the in-memory delivery: the messages published from now on are buffered in Postgres,
+// the listeners are told to leave through Draining
+func (aq *Mega) BeginDrain() {
+	// wait for the in-flight publishes, they may still be writing to the streams
+	aq.drainMu.Lock()
+	defer aq.drainMu.Unlock()
+
+	if !aq.isDraining() {
+		close(aq.draining)
+	}
+}
+
+// Drain begins the drain if necessary and waits for the listeners to leave (or ctx to be done).
+// The messages left in the streams are buffered then and the forwarding stops
+func (aq *Mega) Drain(ctx context.Context) {
+	aq.BeginDrain()
+	aq.waitListeners(ctx)
+
+	aq.mu.Lock()
+	streams := make(map[string]stream, len(aq.direct))
+	for tag, s := range aq.direct {
+		streams[tag] = s
+	}
+	aq.mu.Unlock()
+
+	persisted := 0
+
+	for tag, s := range streams {
+	L:
+		for {
+			select {
+			case m := <-s.ch():
+				aq.Requeue(m)
+				persisted++
+
+			default:
+				break L
+			}
+		}
+
+		if n := s.online(); n > 0 {
+			zap.L().Warn("drain deadline is exceeded with listeners online", zap.String("chan_id", tag), zap.Uint32("online", n))
+		}
+	}
+
+	aq.stopForwarding()
+	aq.flushSpool(context.Background())
+
+	zap.L().Info("broker is drained", zap.Int("persisted", persisted))
+}
+
+func (aq *Mega) waitListeners(ctx context.Context) {
+	for {
+		online := uint32(0)
+
+		aq.mu.Lock()
+		for _, s := range aq.direct {
+			online += s.online()
+		}
+		aq.mu.Unlock()
+
+		if online == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-time.After(drainPollInterval):
+		}
+	}
+}
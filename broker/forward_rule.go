@@ -0,0 +1,109 @@
+package broker
+
+import (
+	"errors"
+	"limq/message"
+	"strings"
+)
+
+var ErrInvalidRule = errors.New("invalid forwarding rule")
+
+// ForwardRule republishes the matching messages of a channel to Destination
+type ForwardRule struct {
+	ID          string         `json:"id" yaml:"id"`
+	Destination string         `json:"destination" yaml:"destination"`
+	Filter      *ForwardFilter `json:"filter,omitempty" yaml:"filter"`
+
+	// Scope overrides the scope of the republished message: "all", "one" or empty to keep it
+	Scope string `json:"scope,omitempty" yaml:"scope"`
+}
+
+// ForwardFilter matches the messages of any of Types (all if empty)
+// carrying all the Headers values
+type ForwardFilter struct {
+	Types   []string          `json:"types,omitempty" yaml:"types"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers"`
+}
+
+// Validate checks everything except for the destination tag format, which is up to the rules storage
+func (r ForwardRule) Validate() error {
+	if len(r.Destination) == 0 {
+		return ErrInvalidRule
+	}
+
+	switch strings.ToLower(r.Scope) {
+	case "", "all", "one":
+	default:
+		return ErrInvalidRule
+	}
+
+	if r.Filter != nil {
+		for _, t := range r.Filter.Types {
+			if _, ok := message.ParseType(t); !ok || len(t) == 0 {
+				return ErrInvalidRule
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *ForwardFilter) matches(m *message.Message) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.Types) > 0 {
+		matched := false
+
+		for _, t := range f.Types {
+			if typ, ok := message.ParseType(t); ok && typ == m.Type {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	for name, value := range f.Headers {
+		if m.Headers[strings.ToLower(name)] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Forward returns the message to republish to the rule's destination, if it matches the rule.
+// The current channel is appended to the path of the returned message
+func (r ForwardRule) Forward(m message.Message) (message.Message, bool) {
+	if !r.Filter.matches(&m) {
+		return m, false
+	}
+
+	path := make([]string, len(m.Path), len(m.Path)+1)
+	copy(path, m.Path)
+
+	m.Path = append(path, m.ChannelID)
+	m.ChannelID = r.Destination
+
+	if len(r.Scope) > 0 {
+		m.Scope = message.ParseScope(r.Scope)
+	}
+
+	return m, true
+}
+
+// PlainRules converts a legacy list of mixin destinations into unconditional rules
+func PlainRules(destinations []string) []ForwardRule {
+	rules := make([]ForwardRule, len(destinations))
+
+	for i, d := range destinations {
+		rules[i] = ForwardRule{ID: d, Destination: d}
+	}
+
+	return rules
+}
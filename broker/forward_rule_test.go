@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"limq/message"
+	"testing"
+)
+
+func TestForwardRule(t *testing.T) {
+	rule := ForwardRule{
+		Destination: "0123456789abcdef",
+		Filter:      &ForwardFilter{Types: []string{"text"}, Headers: map[string]string{"Level": "error"}},
+		Scope:       "one",
+	}
+
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := message.Message{
+		Type:      message.TypeText,
+		Scope:     message.ScopeNotifyAll,
+		ChannelID: "fedcba9876543210",
+		Headers:   map[string]string{"level": "error"},
+	}
+
+	forwarded, ok := rule.Forward(m)
+	if !ok || forwarded.ChannelID != rule.Destination || forwarded.Scope != message.ScopeNotifyOne {
+		t.Errorf("unexpected forward %+v, %v", forwarded, ok)
+	}
+
+	if forwarded.Origin() != m.ChannelID || forwarded.Hops() != 1 || m.Hops() != 0 {
+		t.Errorf("unexpected provenance %v", forwarded.Path)
+	}
+
+	m.Headers["level"] = "info"
+	if _, ok := rule.Forward(m); ok {
+		t.Error("header filter is not applied")
+	}
+
+	m.Headers["level"] = "error"
+	m.Type = message.TypeBinary
+	if _, ok := rule.Forward(m); ok {
+		t.Error("type filter is not applied")
+	}
+
+	if err := (ForwardRule{Destination: rule.Destination, Scope: "some"}).Validate(); err == nil {
+		t.Error("unknown scope is accepted")
+	}
+}
@@ -0,0 +1,354 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"limq/message"
+	"limq/metrics"
+	"limq/storage"
+	"limq/tracing"
+	"sync"
+	"time"
+)
+
+// DefaultMaxHops is the default limit of forwards a message may go through
+const DefaultMaxHops = 8
+
+const (
+	forwardBatch        = 64
+	forwardPollInterval = time.Second
+	forwardLease        = 30 * time.Second
+	forwardMaxAttempts  = 10
+	forwardMinBackoff   = time.Second
+	forwardMaxBackoff   = 5 * time.Minute
+
+	// forwardSpoolLimit bounds the jobs held in memory while Postgres is unavailable
+	forwardSpoolLimit = 4096
+)
+
+// ForwardStats are the counters of a forwarding rule.
+// Delivered and Failed are counted by this replica since its start, Pending is global
+type ForwardStats struct {
+	RuleID      string `json:"id"`
+	Destination string `json:"destination"`
+	Delivered   int64  `json:"delivered"`
+	Failed      int64  `json:"failed"`
+	Pending     int64  `json:"pending"`
+}
+
+type ruleKey struct {
+	source, rule string
+}
+
+type ruleCounters struct {
+	delivered, failed, spooled int64
+}
+
+// forwarder persists forward jobs and delivers them in the background, retrying with backoff
+type forwarder struct {
+	mu       *sync.Mutex
+	spool    []storage.ForwardJob
+	counters map[ruleKey]*ruleCounters
+	wake     chan struct{}
+}
+
+func newForwarder() *forwarder {
+	return &forwarder{
+		mu:       &sync.Mutex{},
+		counters: map[ruleKey]*ruleCounters{},
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func (f *forwarder) count(job *storage.ForwardJob, update func(c *ruleCounters)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := ruleKey{job.Source, job.RuleID}
+
+	c, ok := f.counters[key]
+	if !ok {
+		c = &ruleCounters{}
+		f.counters[key] = c
+	}
+
+	update(c)
+}
+
+func (f *forwarder) notify() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+func forwardBackoff(attempts int) time.Duration {
+	delay := forwardMinBackoff
+
+	for i := 1; i < attempts && delay < forwardMaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > forwardMaxBackoff {
+		delay = forwardMaxBackoff
+	}
+
+	return delay
+}
+
+// forwardJobs evaluates the forwarding rules of the m.ChannelID channel
+func (aq *Mega) forwardJobs(m message.Message) []storage.ForwardJob {
+	tag := m.ChannelID
+
+	var jobs []storage.ForwardJob
+
+	for _, rule := range aq.mman.GetRules(tag) {
+		forwarded, ok := rule.Forward(m)
+		if !ok {
+			continue
+		}
+
+		job := storage.ForwardJob{Source: tag, RuleID: rule.ID, Message: forwarded}
+
+		if !aq.forwardable(&forwarded) {
+			metrics.Forwards.Inc("dropped")
+			aq.forwarder.count(&job, func(c *ruleCounters) { c.failed++ })
+			continue
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+// forwardable enforces the hop limit and cuts off the loops within the forwarding path
+func (aq *Mega) forwardable(m *message.Message) bool {
+	if contains(m.Path, m.ChannelID) {
+		zap.L().Warn("forwarding: circular dependency detected",
+			zap.String("chan_id", m.ChannelID), zap.Strings("path", m.Path))
+
+		return false
+	}
+
+	if m.Hops() > aq.maxHops {
+		zap.L().Warn("forwarding: hop limit exceeded",
+			zap.String("chan_id", m.ChannelID), zap.Strings("path", m.Path))
+
+		return false
+	}
+
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enqueueForwards persists the jobs, holding them in memory while Postgres is unavailable
+func (aq *Mega) enqueueForwards(jobs []storage.ForwardJob) {
+	if len(jobs) == 0 {
+		return
+	}
+
+	err := aq.store.EnqueueForwards(context.Background(), jobs)
+	if err == nil {
+		aq.forwarder.notify()
+		return
+	}
+
+	zap.L().Warn("unable to persist forward jobs, spooling", zap.String("chan_id", jobs[0].Source), zap.Error(err))
+
+	f := aq.forwarder
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range jobs {
+		job := &jobs[i]
+		key := ruleKey{job.Source, job.RuleID}
+
+		if f.counters[key] == nil {
+			f.counters[key] = &ruleCounters{}
+		}
+
+		if len(f.spool) >= forwardSpoolLimit {
+			zap.L().Error("forward spool is full, dropping the job", zap.String("chan_id", job.Source),
+				zap.String("rule_id", job.RuleID))
+
+			metrics.Forwards.Inc("failed")
+			f.counters[key].failed++
+
+			continue
+		}
+
+		f.spool = append(f.spool, *job)
+		f.counters[key].spooled++
+	}
+}
+
+// flushSpool retries persisting the spooled jobs
+func (aq *Mega) flushSpool(ctx context.Context) {
+	f := aq.forwarder
+
+	f.mu.Lock()
+	spool := f.spool
+	f.spool = nil
+	f.mu.Unlock()
+
+	if len(spool) == 0 {
+		return
+	}
+
+	err := aq.store.EnqueueForwards(ctx, spool)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err != nil {
+		// keep the order, the jobs spooled meanwhile go after
+		f.spool = append(spool, f.spool...)
+		return
+	}
+
+	for i := range spool {
+		f.counters[ruleKey{spool[i].Source, spool[i].RuleID}].spooled--
+	}
+}
+
+// deliver publishes the job's message and enqueues the forwards of its destination
+func (aq *Mega) deliver(job *storage.ForwardJob) (err error) {
+	m := job.Message
+
+	span := tracing.Start(m.TraceParent, "limq.forward", tracing.KindInternal)
+	span.SetAttribute("limq.channel", job.Source)
+	span.SetAttribute("limq.rule_id", job.RuleID)
+	span.SetAttribute("limq.destination", m.ChannelID)
+
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	m.TraceParent = tracing.TraceparentOf(span, m.TraceParent)
+	forwarded := m
+
+	if err := aq.Publish(&m); err != nil {
+		return err
+	}
+
+	aq.enqueueForwards(aq.forwardJobs(forwarded))
+	return nil
+}
+
+func (aq *Mega) processForwards(ctx context.Context) (int, error) {
+	jobs, err := aq.store.ClaimForwards(ctx, forwardBatch, forwardLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		err := aq.deliver(job)
+
+		switch {
+		case err == nil:
+			metrics.Forwards.Inc("delivered")
+			aq.forwarder.count(job, func(c *ruleCounters) { c.delivered++ })
+			err = aq.store.CompleteForward(ctx, job.ID)
+
+		case errors.Is(err, ErrMessageIsTooLarge), errors.Is(err, ErrMessageIsEmpty), job.Attempts >= forwardMaxAttempts:
+			zap.L().Error("forwarding failed, giving up", zap.String("chan_id", job.Source),
+				zap.String("rule_id", job.RuleID), zap.Int("attempts", job.Attempts), zap.Error(err))
+
+			metrics.Forwards.Inc("failed")
+			aq.forwarder.count(job, func(c *ruleCounters) { c.failed++ })
+			err = aq.store.CompleteForward(ctx, job.ID)
+
+		default:
+			zap.L().Warn("forwarding failed, retrying", zap.String("chan_id", job.Source),
+				zap.String("rule_id", job.RuleID), zap.Int("attempts", job.Attempts), zap.Error(err))
+
+			metrics.Forwards.Inc("retried")
+			err = aq.store.RetryForward(ctx, job.ID, forwardBackoff(job.Attempts), err.Error())
+		}
+
+		if err != nil {
+			// the job becomes due again once its lease is over
+			zap.L().Warn("unable to update forward job", zap.Int64("id", job.ID), zap.Error(err))
+		}
+	}
+
+	return len(jobs), nil
+}
+
+// runForwarding delivers the forward jobs of every replica until ctx is done
+func (aq *Mega) runForwarding(ctx context.Context) {
+	failures := 0
+
+	for {
+		aq.flushSpool(ctx)
+
+		n, err := aq.processForwards(ctx)
+		if err != nil {
+			failures++
+			zap.L().Warn("unable to claim forward jobs", zap.Error(err))
+		} else {
+			failures = 0
+		}
+
+		// a full batch means more jobs are likely due
+		if err == nil && n == forwardBatch {
+			continue
+		}
+
+		wait := forwardPollInterval
+		if failures > 0 {
+			wait = forwardBackoff(failures)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-aq.forwarder.wake:
+
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ForwardStats reports the counters of the forwarding rules of the channel
+func (aq *Mega) ForwardStats(ctx context.Context, tag string) ([]ForwardStats, error) {
+	pending, err := aq.store.PendingForwards(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := aq.mman.GetRules(tag)
+	stats := make([]ForwardStats, len(rules))
+
+	f := aq.forwarder
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, rule := range rules {
+		stats[i] = ForwardStats{RuleID: rule.ID, Destination: rule.Destination, Pending: pending[rule.ID]}
+
+		if c, ok := f.counters[ruleKey{tag, rule.ID}]; ok {
+			stats[i].Delivered = c.delivered
+			stats[i].Failed = c.failed
+			stats[i].Pending += c.spooled
+		}
+	}
+
+	return stats, nil
+}
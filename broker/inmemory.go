@@ -12,16 +12,25 @@ import (
 
 // InMemory works in a multicast mode and holds buffered data in the process' memory
 type InMemory struct {
-	mu   *sync.Mutex
-	wm   map[string]stream
-	mman MixinManager
+	mu      *sync.Mutex
+	wm      map[string]stream
+	mman    MixinManager
+	maxHops int
 }
 
 func NewGQ(mman MixinManager) *InMemory {
 	return &InMemory{
-		mu:   &sync.Mutex{},
-		wm:   map[string]stream{},
-		mman: mman,
+		mu:      &sync.Mutex{},
+		wm:      map[string]stream{},
+		mman:    mman,
+		maxHops: DefaultMaxHops,
+	}
+}
+
+// SetMaxHops limits the forwards a message may go through
+func (gq *InMemory) SetMaxHops(hops int) {
+	if hops > 0 {
+		gq.maxHops = hops
 	}
 }
 
@@ -39,7 +48,7 @@ func (gq *InMemory) acquire(tag string) stream {
 }
 
 func (gq *InMemory) PostWithTimeout(m *message.Message, to time.Duration) (ok bool) {
-	if len(m.Payload) > quota.MaxMessageSize {
+	if len(m.Payload) > quota.Current().MaxMessageSize {
 		return false
 	}
 
@@ -67,7 +76,7 @@ func (gq *InMemory) PostWithTimeout(m *message.Message, to time.Duration) (ok bo
 }
 
 func (gq *InMemory) PostImmediately(m *message.Message) (ok bool) {
-	if len(m.Payload) > quota.MaxMessageSize {
+	if len(m.Payload) > quota.Current().MaxMessageSize {
 		return false
 	}
 
@@ -123,14 +132,15 @@ func (gq *InMemory) QueueSize(tag string) int {
 	return len(s.ch())
 }
 
-func (gq *InMemory) repost(visited *util.Set[string], tag string, m message.Message, postToThis bool) {
+// repost evaluates the forwarding rules of the m.ChannelID channel
+func (gq *InMemory) repost(visited *util.Set[string], m message.Message, postToThis bool) {
+	tag := m.ChannelID
+
 	if visited.Has(tag) {
 		zap.L().Warn("republish for mixed-in broker: circular dependency detected", zap.String("chan_id", tag))
 		return
 	}
 
-	m.ChannelID = tag
-
 	if postToThis {
 		ok := gq.PostImmediately(&m)
 
@@ -140,17 +150,29 @@ func (gq *InMemory) repost(visited *util.Set[string], tag string, m message.Mess
 	}
 
 	visited.Add(tag)
-	tags := gq.mman.GetForwards(tag)
 
-	for _, t := range tags {
-		gq.repost(visited, t, m, true)
+	for _, rule := range gq.mman.GetRules(tag) {
+		forwarded, ok := rule.Forward(m)
+		if !ok {
+			continue
+		}
+
+		if forwarded.Hops() > gq.maxHops {
+			zap.L().Warn("republish for mixed-in broker: hop limit exceeded", zap.String("chan_id", tag))
+			continue
+		}
+
+		gq.repost(visited, forwarded, true)
 	}
 }
 
 func (gq *InMemory) PostImmediatelyWithMixins(tag string, m *message.Message) (ok bool) {
 	ok = gq.PostImmediately(m)
 
-	go gq.repost(util.NewSet[string](), tag, *m, false)
+	forwarded := *m
+	forwarded.ChannelID = tag
+
+	go gq.repost(util.NewSet[string](), forwarded, false)
 
 	return
 }
@@ -0,0 +1,36 @@
+package broker
+
+import (
+	"limq/message"
+	"testing"
+	"time"
+)
+
+type chainRules map[string]string
+
+func (c chainRules) GetRules(tag string) []ForwardRule {
+	if destination, ok := c[tag]; ok {
+		return []ForwardRule{{Destination: destination}}
+	}
+
+	return nil
+}
+
+func TestInMemoryMaxHops(t *testing.T) {
+	gq := NewGQ(chainRules{"a": "b", "b": "c"})
+	gq.SetMaxHops(1)
+
+	gq.PostImmediatelyWithMixins("a", &message.Message{ChannelID: "a", Payload: []byte("hello")})
+
+	deadline := time.Now().Add(time.Second)
+	for gq.QueueSize("b") == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the forward to c would be the second hop
+	time.Sleep(50 * time.Millisecond)
+
+	if gq.QueueSize("b") != 1 || gq.QueueSize("c") != 0 {
+		t.Errorf("unexpected queues: b %d, c %d", gq.QueueSize("b"), gq.QueueSize("c"))
+	}
+}
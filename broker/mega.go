@@ -3,14 +3,13 @@ package broker
 import (
 	"context"
 	"errors"
-	"github.com/emmitrin/util"
-	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/pgxpool"
 	"go.uber.org/zap"
 	"limq/message"
+	"limq/metrics"
 	"limq/quota"
 	"limq/storage"
 	"sync"
+	"time"
 )
 
 var (
@@ -25,23 +24,49 @@ const (
 
 // Mega works in a multicast mode (all receivers can receive the same message).
 // If a message is posted onto the broker which has zero subscribers at the time,
-// it will be buffered in DBMS
+// it will be buffered in the Storage
 type Mega struct {
-	pool   *pgxpool.Pool
 	direct map[string]stream
 	mman   MixinManager
 	mu     *sync.Mutex
 
-	keeper *storage.Keeper
+	store     Storage
+	forwarder *forwarder
+	maxHops   int
+
+	// stopForwarding stops the forward jobs delivery
+	stopForwarding context.CancelFunc
+
+	// draining is closed on Drain, drainMu is held for reading by the publishes
+	draining chan struct{}
+	drainMu  *sync.RWMutex
+}
+
+// NewMega starts delivering the persisted forward jobs in the background
+func NewMega(store Storage, mman MixinManager) *Mega {
+	aq := &Mega{
+		mu:        &sync.Mutex{},
+		direct:    map[string]stream{},
+		mman:      mman,
+		store:     store,
+		forwarder: newForwarder(),
+		maxHops:   DefaultMaxHops,
+		draining:  make(chan struct{}),
+		drainMu:   &sync.RWMutex{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	aq.stopForwarding = cancel
+
+	go aq.runForwarding(ctx)
+
+	return aq
 }
 
-func NewMega(pool *pgxpool.Pool, mman MixinManager) *Mega {
-	return &Mega{
-		mu:     &sync.Mutex{},
-		direct: map[string]stream{},
-		mman:   mman,
-		pool:   pool,
-		keeper: storage.NewKeeper(pool),
+// SetMaxHops limits the forwards a message may go through, the limit holds across replicas
+func (aq *Mega) SetMaxHops(hops int) {
+	if hops > 0 {
+		aq.maxHops = hops
 	}
 }
 
@@ -59,7 +84,7 @@ func (aq *Mega) acquire(tag string) stream {
 }
 
 func (aq *Mega) Publish(m *message.Message) error {
-	if len(m.Payload) > quota.MaxMessageSize {
+	if len(m.Payload) > quota.Current().MaxMessageSize {
 		return ErrMessageIsTooLarge
 	}
 
@@ -67,11 +92,14 @@ func (aq *Mega) Publish(m *message.Message) error {
 		return ErrMessageIsEmpty
 	}
 
+	aq.drainMu.RLock()
+	defer aq.drainMu.RUnlock()
+
 	streamHandler := aq.acquire(m.ChannelID)
 
 	online := streamHandler.online()
-	if online == 0 {
-		return aq.keeper.Put(m)
+	if online == 0 || aq.isDraining() {
+		return aq.store.Put(m)
 	}
 
 	switch m.Scope {
@@ -85,57 +113,15 @@ func (aq *Mega) Publish(m *message.Message) error {
 	return nil
 }
 
-func (aq *Mega) readBuffered(ctx context.Context, tag string) (m *message.Message, err error) {
-	conn, err := aq.pool.Acquire(ctx)
-	if err != nil {
-		zap.L().Error("unable to acquire db conn", zap.Error(err), zap.String("tag", tag))
-		return nil, err
-	}
+func (aq *Mega) readBuffered(ctx context.Context, tag string) (*message.Message, error) {
+	defer metrics.ReadBuffered.Since(time.Now())
 
-	defer conn.Release()
-
-	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-	if err != nil {
-		zap.L().Error("unable to acquire db tx", zap.Error(err), zap.String("tag", tag))
-		return nil, err
+	m, err := aq.store.Take(ctx, tag)
+	if errors.Is(err, storage.ErrNoMessages) {
+		return nil, ErrNoBufferedMessages
 	}
 
-	row := tx.QueryRow(
-		ctx,
-		`DELETE FROM messages
-			WHERE id = (
-				SELECT id FROM messages WHERE tag = $1 ORDER BY ID ASC LIMIT 1
-			) RETURNING msg_type, content`,
-		tag,
-	)
-
-	nm := &message.Message{}
-
-	// manually set scope to one
-	// buffered messages are returned only to the race-winner listener, by design
-	nm.Scope = message.ScopeNotifyOne
-
-	err = row.Scan(&nm.Type, &nm.Payload)
-	if err != nil {
-		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-			zap.L().Error("unable to rollback db tx", zap.Error(rollbackErr))
-		}
-
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrNoBufferedMessages
-		}
-
-		zap.L().Error("pgx error", zap.Error(err), zap.String("tag", tag))
-		return nil, err
-	}
-
-	err = tx.Commit(ctx)
-	if err != nil {
-		zap.L().Error("unable to commit db tx", zap.Error(err), zap.String("tag", tag))
-		return nil, err
-	}
-
-	return nm, nil
+	return m, err
 }
 
 func (aq *Mega) Listen(ctx context.Context, tag string) (m *message.Message) {
@@ -160,6 +146,9 @@ func (aq *Mega) Listen(ctx context.Context, tag string) (m *message.Message) {
 	case <-ctx.Done():
 		return nil
 
+	case <-aq.draining:
+		return nil
+
 	case val := <-queue:
 		return val
 	}
@@ -183,6 +172,10 @@ func (aq *Mega) streamDispatch(ctx context.Context, tag string, target chan *mes
 				close(direct)
 				return
 
+			case <-aq.draining:
+				close(direct)
+				return
+
 			case val := <-queue:
 				direct <- val
 			}
@@ -218,41 +211,44 @@ func (aq *Mega) ListenStream(ctx context.Context, tag string) chan *message.Mess
 	return c
 }
 
-func (aq *Mega) republish(visited *util.Set[string], tag string, m message.Message, publishCurrent bool) {
-	if visited.Has(tag) {
-		zap.L().Warn("republish for mixed-in broker: circular dependency detected", zap.String("chan_id", tag))
-		return
+func (aq *Mega) PublishWithMixin(tag string, m *message.Message) error {
+	err := aq.Publish(m)
+	if err != nil {
+		return err
 	}
 
-	m.ChannelID = tag
-
-	if publishCurrent {
-		err := aq.Publish(&m)
+	forwarded := *m
+	forwarded.ChannelID = tag
 
-		if err != nil {
-			zap.L().Warn("unable to publish to mixed-in broker", zap.String("chan_id", tag), zap.Error(err))
-		}
-	}
+	aq.enqueueForwards(aq.forwardJobs(forwarded))
+	return nil
+}
 
-	visited.Add(tag)
-	tags := aq.mman.GetForwards(tag)
+// Purge drops the buffered messages of the channel.
+// Messages already dispatched to the online listeners are not affected
+func (aq *Mega) Purge(ctx context.Context, tag string) (int64, error) {
+	return aq.store.Purge(ctx, tag)
+}
 
-	for _, t := range tags {
-		aq.republish(visited, t, m, true)
-	}
+// Backlog returns the buffered messages of the channel without consuming them
+func (aq *Mega) Backlog(ctx context.Context, tag string) ([]*message.Message, error) {
+	return aq.store.Backlog(ctx, tag)
 }
 
-func (aq *Mega) PublishWithMixin(tag string, m *message.Message) error {
-	err := aq.Publish(m)
-	if err != nil {
-		return err
+// Buffer appends m to the channel's buffered messages, bypassing the online listeners and the forwarding
+func (aq *Mega) Buffer(m *message.Message) error {
+	if len(m.Payload) > quota.Current().MaxMessageSize {
+		return ErrMessageIsTooLarge
 	}
 
-	if m.Scope != message.ScopeNotifyAll {
-		return nil
+	if len(m.Payload) == 0 {
+		return ErrMessageIsEmpty
 	}
 
-	go aq.republish(util.NewSet[string](), tag, *m, false)
+	return aq.store.Put(m)
+}
 
-	return nil
+// BufferedCounts returns the number of buffered messages by channel
+func (aq *Mega) BufferedCounts(ctx context.Context) (map[string]int64, error) {
+	return aq.store.BufferedCounts(ctx)
 }
@@ -0,0 +1,78 @@
+package broker
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.uber.org/zap"
+	"limq/metrics"
+)
+
+// CollectMetrics refreshes the gauges describing the broker state.
+// The per channel gauges are collected only if perChannel is set, their cardinality is the number of channels
+func (aq *Mega) CollectMetrics(ctx context.Context, perChannel bool) {
+	online := map[string]uint32{}
+	var total uint32
+
+	aq.mu.Lock()
+
+	for tag, s := range aq.direct {
+		if n := s.online(); n > 0 {
+			online[tag] = n
+			total += n
+		}
+	}
+
+	aq.mu.Unlock()
+
+	metrics.OnlineListeners.Set(float64(total))
+
+	if perChannel {
+		metrics.ChannelOnlineListeners.Reset()
+
+		for tag, n := range online {
+			metrics.ChannelOnlineListeners.Set(float64(n), tag)
+		}
+	}
+
+	if pool, ok := aq.store.(poolStater); ok {
+		collectPoolMetrics(pool.Stat())
+	}
+
+	buffered, err := aq.store.BufferedCounts(ctx)
+	if err != nil {
+		zap.L().Warn("unable to count buffered messages", zap.Error(err))
+		return
+	}
+
+	var sum int64
+	for _, n := range buffered {
+		sum += n
+	}
+
+	metrics.BufferedMessages.Set(float64(sum))
+
+	if perChannel {
+		metrics.ChannelBufferedMessages.Reset()
+
+		for tag, n := range buffered {
+			metrics.ChannelBufferedMessages.Set(float64(n), tag)
+		}
+	}
+}
+
+// poolStater is implemented by the storages backed by a connection pool
+type poolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+func collectPoolMetrics(stat *pgxpool.Stat) {
+	metrics.PoolConnections.Set(float64(stat.AcquiredConns()), "acquired")
+	metrics.PoolConnections.Set(float64(stat.IdleConns()), "idle")
+	metrics.PoolConnections.Set(float64(stat.ConstructingConns()), "constructing")
+	metrics.PoolConnections.Set(float64(stat.TotalConns()), "total")
+	metrics.PoolConnections.Set(float64(stat.MaxConns()), "max")
+
+	metrics.PoolAcquires.Set(float64(stat.AcquireCount()), "all")
+	metrics.PoolAcquires.Set(float64(stat.EmptyAcquireCount()), "empty")
+	metrics.PoolAcquires.Set(float64(stat.CanceledAcquireCount()), "canceled")
+}
@@ -1,5 +1,5 @@
 package broker
 
 type MixinManager interface {
-	GetForwards(tag string) []string
+	GetRules(tag string) []ForwardRule
 }
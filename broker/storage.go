@@ -0,0 +1,27 @@
+package broker
+
+import (
+	"context"
+	"limq/message"
+	"limq/storage"
+	"time"
+)
+
+// Storage persists the buffered messages and the forward jobs of Mega.
+// storage.Keeper keeps them in Postgres, storage.Memory in the process' memory
+type Storage interface {
+	Put(m *message.Message) error
+
+	// Take removes the oldest buffered message of the channel, storage.ErrNoMessages is returned if there is none
+	Take(ctx context.Context, tag string) (*message.Message, error)
+
+	Purge(ctx context.Context, tag string) (int64, error)
+	Backlog(ctx context.Context, tag string) ([]*message.Message, error)
+	BufferedCounts(ctx context.Context) (map[string]int64, error)
+
+	EnqueueForwards(ctx context.Context, jobs []storage.ForwardJob) error
+	ClaimForwards(ctx context.Context, limit int, lease time.Duration) ([]storage.ForwardJob, error)
+	CompleteForward(ctx context.Context, id int64) error
+	RetryForward(ctx context.Context, id int64, after time.Duration, reason string) error
+	PendingForwards(ctx context.Context, source string) (map[string]int64, error)
+}
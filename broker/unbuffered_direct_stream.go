@@ -60,7 +60,7 @@ L:
 }
 
 func newUnbufferedDirectS() stream {
-	return &unbufferedDirectStream{c: make(chan *message.Message, quota.MaxBufferedMessages)}
+	return &unbufferedDirectStream{c: make(chan *message.Message, quota.Current().MaxBufferedMessages)}
 }
 
 func (s *unbufferedDirectStream) ch() chan *message.Message {
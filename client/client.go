@@ -0,0 +1,160 @@
+// Package client is the Go SDK of the limq HTTP and WebSocket API
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/fasthttp/websocket"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMinBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Options holds the tunables of a Client, the zero value is usable
+type Options struct {
+	// HTTPClient sends /publish and /listen, http.DefaultClient if nil.
+	// Its Timeout must exceed the listen timeouts
+	HTTPClient *http.Client
+	// Dialer opens /subscribe connections, websocket.DefaultDialer if nil
+	Dialer *websocket.Dialer
+
+	// MinBackoff and MaxBackoff bound the delay between the reconnects of a subscription
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Client calls the limq API with an access key (or a signed token).
+// It's safe for concurrent use
+type Client struct {
+	base *url.URL
+	key  string
+	opts Options
+}
+
+// New creates a client of the server at base (e.g. http://localhost:8081).
+// An empty key suits the mTLS clients authenticated by their certificate
+func New(base, key string, opts Options) (*Client, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported address scheme %q", u.Scheme)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+
+	if opts.Dialer == nil {
+		opts.Dialer = websocket.DefaultDialer
+	}
+
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+
+	return &Client{base: u, key: key, opts: opts}, nil
+}
+
+// endpoint is the URL of the route for the channel, the key's primary channel if empty
+func (c *Client) endpoint(route, channel string) *url.URL {
+	u := *c.base
+
+	if len(channel) > 0 {
+		u.Path += "/channel/" + url.PathEscape(channel)
+	}
+
+	u.Path += "/" + route + c.key
+
+	return &u
+}
+
+func (c *Client) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	resp, err := c.opts.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// errorBody is the JSON body of the API responses
+type errorBody struct {
+	Code       int    `json:"status_code"`
+	StatusText string `json:"status_text"`
+	RequestID  string `json:"request_id"`
+}
+
+// readError builds the Error of a failed response, it reads up to a small part of the body
+func readError(resp *http.Response) *Error {
+	e := &Error{HTTPStatus: resp.StatusCode, Code: codeUnknownError, RequestID: resp.Header.Get("X-Request-ID")}
+
+	body := errorBody{}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if err := json.Unmarshal(raw, &body); err == nil && body.Code != codeOk {
+		e.Code, e.Text = body.Code, body.StatusText
+
+		if len(body.RequestID) > 0 {
+			e.RequestID = body.RequestID
+		}
+	} else {
+		e.Code = statusCode(resp.StatusCode)
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return e
+}
+
+// statusCode guesses the API status code of a response without a JSON body
+func statusCode(httpStatus int) int {
+	switch httpStatus {
+	case http.StatusNotModified, http.StatusGatewayTimeout:
+		return codeTimeout
+
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return codeAuthenticationError
+
+	case http.StatusNotFound:
+		return codeNotFound
+
+	case http.StatusConflict:
+		return codeAnotherClientIsOnline
+
+	case http.StatusTooManyRequests:
+		return codeRateLimited
+
+	case http.StatusServiceUnavailable:
+		return codeShuttingDown
+
+	case http.StatusBadRequest:
+		return codeInvalidArgument
+
+	default:
+		return codeUnknownError
+	}
+}
@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"limq/api"
+	"limq/authenticator"
+	"limq/client/limqtest"
+	"limq/message"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	testKey     = "0123456789abcdef0123456789abcdef"
+	testChannel = "0123456789abcdef"
+)
+
+func newTestClient(t *testing.T, key string) (*Client, *limqtest.Server) {
+	s := limqtest.NewServer(authenticator.StaticConfig{Keys: []authenticator.StaticKey{limqtest.Key(testKey, testChannel)}})
+	t.Cleanup(s.Close)
+
+	c, err := New(s.URL, key, Options{MinBackoff: 10 * time.Millisecond, MaxBackoff: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c, s
+}
+
+// receive publishes until the subscription delivers, it may connect after a publish, which would not reach it then
+func receive(t *testing.T, c *Client, s *Subscription, text string) *Message {
+	deadline := time.After(5 * time.Second)
+
+	for {
+		// the connections to a restarted server fail once
+		var e *Error
+		if err := c.Publish(context.Background(), Text(text)); errors.As(err, &e) && !errors.Is(err, ErrShuttingDown) {
+			t.Fatal(err)
+		}
+
+		select {
+		case m, ok := <-s.Messages():
+			if !ok {
+				t.Fatal("subscription has ended", s.Err())
+			}
+
+			return m
+
+		case <-time.After(50 * time.Millisecond):
+
+		case <-deadline:
+			t.Fatal("no message is delivered")
+		}
+	}
+}
+
+func TestCodes(t *testing.T) {
+	codes := map[int]int{
+		codeOk:                    api.CodeOk,
+		codeAuthenticationError:   api.CodeAuthenticationError,
+		codeUnknownError:          api.CodeUnknownError,
+		codeChannelIsFull:         api.CodeChannelIsFull,
+		codeTimeout:               api.CodeTimeout,
+		codeAnotherClientIsOnline: api.CodeAnotherClientIsOnline,
+		codeUnknownMessageType:    api.CodeUnknownMessageType,
+		codeMessageIsEmpty:        api.CodeMessageIsEmpty,
+		codeMessageIsTooBig:       api.CodeMessageIsTooBig,
+		codeInvalidArgument:       api.CodeInvalidArgument,
+		codeOriginNotAllowed:      api.CodeOriginNotAllowed,
+		codeNotFound:              api.CodeNotFound,
+		codeRateLimited:           api.CodeRateLimited,
+		codeShuttingDown:          api.CodeShuttingDown,
+		codePublishInProgress:     api.CodePublishInProgress,
+	}
+
+	for code, apiCode := range codes {
+		if code != apiCode {
+			t.Errorf("code %d must match the API code %d", code, apiCode)
+		}
+
+		if _, ok := codeErrors[code]; !ok && code != codeOk {
+			t.Errorf("code %d has no error", code)
+		}
+	}
+
+	err := error(&Error{Code: codeRateLimited, HTTPStatus: http.StatusTooManyRequests})
+	if !errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTimeout) {
+		t.Error("errors must match by code", err)
+	}
+}
+
+func TestPublishListen(t *testing.T) {
+	c, _ := newTestClient(t, testKey)
+	ctx := context.Background()
+
+	m := Text("hello")
+	m.Scope = message.ScopeNotifyOne
+	m.Headers = map[string]string{"kind": "greeting"}
+
+	if err := c.Publish(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.Listen(ctx, "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Text() != "hello" || got.Type != message.TypeText || got.Headers["kind"] != "greeting" {
+		t.Errorf("unexpected message %+v", got)
+	}
+
+	if _, err := c.Listen(ctx, "", time.Second); !errors.Is(err, ErrTimeout) {
+		t.Error("empty channel must time out", err)
+	}
+
+	if err := c.Publish(ctx, Binary(nil)); !errors.Is(err, ErrMessageIsEmpty) {
+		t.Error("empty message must be rejected", err)
+	}
+}
+
+func TestPublishIdempotent(t *testing.T) {
+	c, _ := newTestClient(t, testKey)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		m := Text("once")
+		m.Scope = message.ScopeNotifyOne
+		m.IdempotencyKey = "publish-1"
+
+		if err := c.Publish(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := c.Listen(ctx, "", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if m, err := c.Listen(ctx, "", time.Second); !errors.Is(err, ErrTimeout) {
+		t.Error("retries must not be published", m, err)
+	}
+}
+
+func TestRequestReply(t *testing.T) {
+	c, _ := newTestClient(t, testKey)
+	ctx := context.Background()
+
+	s := c.Subscribe(ctx)
+	defer s.Close()
+
+	go func() {
+		for m := range s.Messages() {
+			reply := Text("re: " + m.Text())
+			reply.Headers = map[string]string{"status": "done"}
+
+			if err := c.Reply(ctx, m, reply); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	// the request may be published before the subscription connects, it's buffered then
+	got, err := c.Request(ctx, Text("ping"), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Text() != "re: ping" || got.Headers["status"] != "done" || len(got.CorrelationID) == 0 {
+		t.Errorf("unexpected reply %+v", got)
+	}
+
+	late := &Message{CorrelationID: got.CorrelationID, ReplyTo: "/reply"}
+	if err := c.Reply(ctx, late, Text("again")); !errors.Is(err, ErrNotFound) {
+		t.Error("a request must be replied once", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	c, server := newTestClient(t, testKey)
+
+	s := c.Subscribe(context.Background())
+	defer s.Close()
+
+	if m := receive(t, c, s, "hello"); m.Text() != "hello" || m.Channel != testChannel {
+		t.Errorf("unexpected message %+v", m)
+	}
+
+	server.Restart()
+
+	if m := receive(t, c, s, "again"); m.Text() != "again" {
+		t.Errorf("unexpected message after the reconnect %+v", m)
+	}
+
+	s.Close()
+
+	if _, ok := <-s.Messages(); ok || s.Err() != nil {
+		t.Error("closed subscription must end without an error", s.Err())
+	}
+}
+
+func TestSubscribeUnauthorized(t *testing.T) {
+	c, _ := newTestClient(t, "unknown")
+
+	s := c.Subscribe(context.Background())
+
+	if _, ok := <-s.Messages(); ok {
+		t.Fatal("unauthorized subscription must not deliver")
+	}
+
+	if !errors.Is(s.Err(), ErrAuthentication) {
+		t.Error("unauthorized subscription must not be retried", s.Err())
+	}
+}
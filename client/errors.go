@@ -0,0 +1,124 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// the status codes of the API, see api/code.go
+const (
+	codeOk = iota
+	codeAuthenticationError
+	codeUnknownError
+	codeChannelIsFull
+	codeTimeout
+	codeAnotherClientIsOnline
+	codeUnknownMessageType
+	codeMessageIsEmpty
+	codeMessageIsTooBig
+	codeInvalidArgument
+	codeOriginNotAllowed
+	codeNotFound
+	codeRateLimited
+	codeShuttingDown
+	codePublishInProgress
+)
+
+var (
+	ErrAuthentication        = errors.New("limq: authentication error")
+	ErrUnknown               = errors.New("limq: server error")
+	ErrChannelIsFull         = errors.New("limq: channel is full")
+	ErrTimeout               = errors.New("limq: no message within the timeout")
+	ErrAnotherClientIsOnline = errors.New("limq: another client is online")
+	ErrUnknownMessageType    = errors.New("limq: unknown message type")
+	ErrMessageIsEmpty        = errors.New("limq: message is empty")
+	ErrMessageIsTooBig       = errors.New("limq: message is too big")
+	ErrInvalidArgument       = errors.New("limq: invalid argument")
+	ErrOriginNotAllowed      = errors.New("limq: origin is not allowed")
+	ErrNotFound              = errors.New("limq: not found")
+	ErrRateLimited           = errors.New("limq: rate limit is exceeded")
+	ErrShuttingDown          = errors.New("limq: server is shutting down")
+	ErrPublishInProgress     = errors.New("limq: publish with the same idempotency key is in progress")
+
+	// ErrUnexpectedResponse is matched by the responses the client doesn't understand
+	ErrUnexpectedResponse = errors.New("limq: unexpected response")
+)
+
+var codeErrors = map[int]error{
+	codeAuthenticationError:   ErrAuthentication,
+	codeUnknownError:          ErrUnknown,
+	codeChannelIsFull:         ErrChannelIsFull,
+	codeTimeout:               ErrTimeout,
+	codeAnotherClientIsOnline: ErrAnotherClientIsOnline,
+	codeUnknownMessageType:    ErrUnknownMessageType,
+	codeMessageIsEmpty:        ErrMessageIsEmpty,
+	codeMessageIsTooBig:       ErrMessageIsTooBig,
+	codeInvalidArgument:       ErrInvalidArgument,
+	codeOriginNotAllowed:      ErrOriginNotAllowed,
+	codeNotFound:              ErrNotFound,
+	codeRateLimited:           ErrRateLimited,
+	codeShuttingDown:          ErrShuttingDown,
+	codePublishInProgress:     ErrPublishInProgress,
+}
+
+// Error is a failure reported by the server. It matches the Err* value of its Code with errors.Is
+type Error struct {
+	// Code is the API status code
+	Code       int
+	Text       string
+	HTTPStatus int
+
+	// RequestID refers to the request in the server's access log
+	RequestID string
+	// RetryAfter is the delay asked by the server, if any
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	text := e.Text
+	if len(text) == 0 {
+		text = e.kind().Error()
+	}
+
+	if len(e.RequestID) > 0 {
+		return fmt.Sprintf("%s (HTTP %d, status code %d, request %s)", text, e.HTTPStatus, e.Code, e.RequestID)
+	}
+
+	return fmt.Sprintf("%s (HTTP %d, status code %d)", text, e.HTTPStatus, e.Code)
+}
+
+func (e *Error) kind() error {
+	if err, ok := codeErrors[e.Code]; ok {
+		return err
+	}
+
+	return ErrUnexpectedResponse
+}
+
+func (e *Error) Is(target error) bool {
+	return e.kind() == target
+}
+
+// retryable reports whether a subscription may reconnect after err
+func retryable(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		// network failures
+		return true
+	}
+
+	switch e.Code {
+	case codeAuthenticationError, codeOriginNotAllowed, codeNotFound, codeInvalidArgument:
+		return false
+
+	case codeAnotherClientIsOnline:
+		// an exclusive listener may go away soon, but taking the connection back from
+		// the newer listener that took it over would make them kick each other forever
+		return e.HTTPStatus == http.StatusConflict
+
+	default:
+		return true
+	}
+}
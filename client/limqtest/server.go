@@ -0,0 +1,81 @@
+// Package limqtest runs an in-process limq server for the unit tests of the API consumers
+package limqtest
+
+import (
+	"context"
+	"github.com/valyala/fasthttp"
+	"limq/api"
+	"limq/authenticator"
+	"limq/idempotency"
+	"net"
+	"strings"
+	"time"
+)
+
+// Server serves the whole limq API in the standalone mode, on a loopback port
+type Server struct {
+	// URL is the base address of the API, e.g. http://127.0.0.1:41234
+	URL string
+
+	auth   authenticator.Authenticator
+	stub   *api.Stub
+	server *fasthttp.Server
+	served chan struct{}
+}
+
+// Key is an access key allowed to publish to and listen to the channel
+func Key(key, tag string) authenticator.StaticKey {
+	return authenticator.StaticKey{Key: key, Tag: tag, Permissions: authenticator.AccessRead | authenticator.AccessWrite}
+}
+
+// NewServer starts a server with the keys and forwarding rules of config, like the file auth backend does.
+// It panics on failure, the caller must Close it
+func NewServer(config authenticator.StaticConfig) *Server {
+	a, err := authenticator.NewStatic(config)
+	if err != nil {
+		panic("limqtest: " + err.Error())
+	}
+
+	s := &Server{auth: authenticator.NewSigned(a, nil)}
+	s.start("127.0.0.1:0")
+
+	return s
+}
+
+func (s *Server) start(address string) {
+	ln, err := net.Listen("tcp4", address)
+	if err != nil {
+		panic("limqtest: " + err.Error())
+	}
+
+	s.URL = "http://" + ln.Addr().String()
+	s.stub = api.NewStub(s.auth, api.Options{
+		CORS:              api.CorsPolicy{AllowedOrigins: []string{"*"}},
+		IdempotencyWindow: idempotency.DefaultWindow,
+	})
+	s.server = &fasthttp.Server{Handler: s.stub.Handler()}
+	s.served = make(chan struct{})
+
+	go func(server *fasthttp.Server, served chan struct{}) {
+		defer close(served)
+		_ = server.Serve(ln)
+	}(s.server, s.served)
+}
+
+// Close drains the server: the subscriptions get the going away close frame and the long polls end
+func (s *Server) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.stub.Drain(ctx)
+	_ = s.server.Shutdown()
+
+	<-s.served
+}
+
+// Restart closes the server and starts a new one at the same URL, like a limq restart.
+// The buffered messages are lost
+func (s *Server) Restart() {
+	s.Close()
+	s.start(strings.TrimPrefix(s.URL, "http://"))
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Listen long-polls the channel (the key's primary one if empty) for a single message.
+// The server waits up to timeout, rounded up to seconds, or its own default if timeout is 0.
+// ErrTimeout is returned if no message comes
+func (c *Client) Listen(ctx context.Context, channel string, timeout time.Duration) (*Message, error) {
+	req, err := http.NewRequest(http.MethodGet, c.endpoint("listen", channel).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout > 0 {
+		seconds := int((timeout + time.Second - 1) / time.Second)
+		req.Header.Set("X-Timeout", strconv.Itoa(seconds))
+	}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+
+	m := &Message{Channel: channel}
+	m.readHeaders(resp.Header)
+
+	m.Payload, err = io.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"limq/message"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const metaHeaderPrefix = "X-Meta-"
+
+// Message is a message to publish or a delivered one
+type Message struct {
+	// Channel is the channel tag. Published messages go to the key's primary channel if it's empty;
+	// a message delivered by Listen has the channel it was asked for
+	Channel string
+
+	Type  message.Type
+	Scope message.Scope
+
+	// Headers are the user-defined metadata, names are lowercase
+	Headers map[string]string
+	Payload []byte
+
+	// TraceParent is the W3C trace context
+	TraceParent string
+
+	// CorrelationID identifies a request awaiting a reply, ReplyTo is the route to reply at.
+	// Both are set on delivery only, see Client.Reply
+	CorrelationID string
+	ReplyTo       string
+
+	// IdempotencyKey deduplicates the retries of a publish, the server remembers it per channel
+	IdempotencyKey string
+
+	// provenance of a forwarded message, set on delivery only
+	Origin string
+	Hops   int
+	Path   []string
+}
+
+// Text creates a text message for every listener of the primary channel
+func Text(text string) *Message {
+	return &Message{Type: message.TypeText, Payload: []byte(text)}
+}
+
+// Binary creates a binary message for every listener of the primary channel
+func Binary(payload []byte) *Message {
+	return &Message{Type: message.TypeBinary, Payload: payload}
+}
+
+// Text returns the payload as a string
+func (m *Message) Text() string {
+	return string(m.Payload)
+}
+
+func (m *Message) writeHeaders(h http.Header) {
+	h.Set("X-Message-Type", m.Type.String())
+	h.Set("X-Scope", m.Scope.String())
+
+	for name, value := range m.Headers {
+		h.Set(metaHeaderPrefix+name, value)
+	}
+
+	if len(m.TraceParent) > 0 {
+		h.Set("traceparent", m.TraceParent)
+	}
+
+	if len(m.IdempotencyKey) > 0 {
+		h.Set("X-Idempotency-Key", m.IdempotencyKey)
+	}
+}
+
+// readHeaders fills the metadata of a message delivered by /listen
+func (m *Message) readHeaders(h http.Header) {
+	m.Type, _ = message.ParseType(h.Get("X-Message-Type"))
+	m.Scope = message.ParseScope(h.Get("X-Message-Scope"))
+	m.TraceParent = h.Get("traceparent")
+
+	for name, values := range h {
+		if len(name) > len(metaHeaderPrefix) && strings.EqualFold(name[:len(metaHeaderPrefix)], metaHeaderPrefix) && len(values) > 0 {
+			if m.Headers == nil {
+				m.Headers = map[string]string{}
+			}
+
+			m.Headers[strings.ToLower(name[len(metaHeaderPrefix):])] = values[0]
+		}
+	}
+
+	m.CorrelationID = h.Get("X-Correlation-ID")
+	m.ReplyTo = h.Get("X-Reply-To")
+
+	if origin := h.Get("X-Origin-Channel"); len(origin) > 0 {
+		m.Origin = origin
+		m.Hops, _ = strconv.Atoi(h.Get("X-Forward-Hops"))
+		m.Path = strings.Split(h.Get("X-Forward-Path"), ",")
+	}
+}
+
+// envelope is a message delivered over /subscribe, see api/envelope.go
+type envelope struct {
+	Channel string `json:"channel_id"`
+	Type    string `json:"type"`
+	Scope   string `json:"scope"`
+
+	Headers map[string]string `json:"headers"`
+
+	Origin string   `json:"origin"`
+	Hops   int      `json:"hops"`
+	Path   []string `json:"path"`
+
+	TraceParent string `json:"traceparent"`
+
+	CorrelationID string `json:"correlation_id"`
+	ReplyTo       string `json:"reply_to"`
+
+	Text *string `json:"text"`
+	Data []byte  `json:"data"`
+}
+
+func (e envelope) message() *Message {
+	m := &Message{
+		Channel:     e.Channel,
+		Scope:       message.ParseScope(e.Scope),
+		Headers:     e.Headers,
+		TraceParent: e.TraceParent,
+		Origin:      e.Origin,
+		Hops:        e.Hops,
+		Path:        e.Path,
+		Payload:     e.Data,
+
+		CorrelationID: e.CorrelationID,
+		ReplyTo:       e.ReplyTo,
+	}
+
+	m.Type, _ = message.ParseType(e.Type)
+
+	if e.Text != nil {
+		m.Payload = []byte(*e.Text)
+	}
+
+	return m
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Publish sends m to its channel
+func (c *Client) Publish(ctx context.Context, m *Message) error {
+	req, err := http.NewRequest(http.MethodPost, c.endpoint("publish", m.Channel).String(), bytes.NewReader(m.Payload))
+	if err != nil {
+		return err
+	}
+
+	m.writeHeaders(req.Header)
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+
+	// some failures are reported with HTTP 200 and a non-zero status code
+	body := errorBody{}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return &Error{Code: -1, Text: "malformed response", HTTPStatus: resp.StatusCode}
+	}
+
+	if body.Code != codeOk {
+		return &Error{Code: body.Code, Text: body.StatusText, HTTPStatus: resp.StatusCode, RequestID: body.RequestID}
+	}
+
+	return nil
+}
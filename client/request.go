@@ -0,0 +1,90 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrNotRequest is returned by Reply for a message which doesn't await a reply
+var ErrNotRequest = errors.New("limq: message is not a request")
+
+// Request publishes m to one listener of its channel and waits for the reply up to timeout,
+// rounded up to seconds, or the server's default if timeout is 0. ErrTimeout is returned if no reply comes
+func (c *Client) Request(ctx context.Context, m *Message, timeout time.Duration) (*Message, error) {
+	req, err := http.NewRequest(http.MethodPost, c.endpoint("request", m.Channel).String(), bytes.NewReader(m.Payload))
+	if err != nil {
+		return nil, err
+	}
+
+	m.writeHeaders(req.Header)
+
+	// requests go to a single responder
+	req.Header.Del("X-Scope")
+
+	if timeout > 0 {
+		seconds := int((timeout + time.Second - 1) / time.Second)
+		req.Header.Set("X-Timeout", strconv.Itoa(seconds))
+	}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+
+	reply := &Message{Channel: m.Channel}
+	reply.readHeaders(resp.Header)
+
+	reply.Payload, err = io.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// Reply answers the request delivered by Listen or Subscribe.
+// ErrNotFound is returned if the request is not awaited any more, e.g. it has timed out
+func (c *Client) Reply(ctx context.Context, request *Message, reply *Message) error {
+	if len(request.CorrelationID) == 0 || len(request.ReplyTo) == 0 {
+		return ErrNotRequest
+	}
+
+	u := *c.base
+	u.Path += request.ReplyTo + c.key
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(reply.Payload))
+	if err != nil {
+		return err
+	}
+
+	reply.writeHeaders(req.Header)
+	req.Header.Set("X-Correlation-ID", request.CorrelationID)
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/fasthttp/websocket"
+	"math/rand"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// closeTakenOver is the close code of a listener kicked by a newer connection
+const closeTakenOver = 4000
+
+// Subscription delivers the messages of a /subscribe stream, reconnecting with backoff when it breaks
+type Subscription struct {
+	messages chan *Message
+	cancel   context.CancelFunc
+	done     chan struct{}
+	err      error
+}
+
+// Subscribe streams the messages of the channels until ctx is done or Close is called.
+// No channels stand for the key's primary channel, "*" for every channel the key may listen to.
+// The connection is re-established on failures except for the ones that retrying can't fix,
+// e.g. ErrAuthentication, and a takeover by another listener (ErrAnotherClientIsOnline)
+func (c *Client) Subscribe(ctx context.Context, channels ...string) *Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &Subscription{
+		messages: make(chan *Message),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	go s.run(ctx, c, c.subscribeURL(channels))
+
+	return s
+}
+
+// Messages is closed once the subscription ends
+func (s *Subscription) Messages() <-chan *Message {
+	return s.messages
+}
+
+// Err is the reason the subscription has ended with, nil if it was closed or its context was done
+func (s *Subscription) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Close ends the subscription and waits for the connection to close
+func (s *Subscription) Close() {
+	s.cancel()
+	<-s.done
+}
+
+func (c *Client) subscribeURL(channels []string) string {
+	u := c.endpoint("subscribe", "")
+
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+
+	q := url.Values{"envelope": {"1"}}
+	if len(channels) > 0 {
+		q.Set("channels", strings.Join(channels, ","))
+	}
+
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+func (s *Subscription) run(ctx context.Context, c *Client, address string) {
+	defer close(s.done)
+	defer close(s.messages)
+
+	backoff := c.opts.MinBackoff
+
+	for {
+		connected, err := s.receive(ctx, c, address)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !retryable(err) {
+			s.err = err
+			return
+		}
+
+		if connected {
+			backoff = c.opts.MinBackoff
+		}
+
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+
+		var e *Error
+		if errors.As(err, &e) && e.RetryAfter > delay {
+			delay = e.RetryAfter
+		}
+
+		if backoff *= 2; backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// receive delivers the messages of a single connection until it breaks
+func (s *Subscription) receive(ctx context.Context, c *Client, address string) (connected bool, err error) {
+	conn, resp, err := c.opts.Dialer.DialContext(ctx, address, nil)
+	if err != nil {
+		if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+			defer resp.Body.Close()
+			return false, readError(resp)
+		}
+
+		return false, err
+	}
+
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	defer close(closed)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			_ = conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+			conn.Close()
+
+		case <-closed:
+		}
+	}()
+
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, closeTakenOver) {
+				return true, &Error{Code: codeAnotherClientIsOnline, Text: err.Error()}
+			}
+
+			return true, err
+		}
+
+		e := envelope{}
+		if err := json.Unmarshal(frame, &e); err != nil {
+			return true, err
+		}
+
+		select {
+		case s.messages <- e.message():
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+}
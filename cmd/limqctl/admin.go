@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const requestTimeout = 30 * time.Second
+
+// admin is a client of the limq admin API
+type admin struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+// apiError is the error body of the limq API
+type apiError struct {
+	Code       int    `json:"status_code"`
+	StatusText string `json:"status_text"`
+	HTTPStatus int    `json:"-"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d, status code %d)", e.StatusText, e.HTTPStatus, e.Code)
+}
+
+func newAdmin(base, token string) *admin {
+	return &admin{
+		base:   strings.TrimSuffix(base, "/"),
+		token:  token,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// do sends the JSON encoded body (if not nil) and returns the raw response body
+func (a *admin) do(method, path string, body any) ([]byte, error) {
+	var reader io.Reader
+
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, a.base+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		e := &apiError{HTTPStatus: resp.StatusCode}
+		if err := json.Unmarshal(raw, e); err != nil || len(e.StatusText) == 0 {
+			e.StatusText = http.StatusText(resp.StatusCode)
+		}
+
+		return nil, e
+	}
+
+	return raw, nil
+}
+
+// get decodes the response into v and returns the raw response too
+func (a *admin) get(path string, v any) ([]byte, error) {
+	raw, err := a.do(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return raw, json.Unmarshal(raw, v)
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the access level bits, see authenticator.AccessLevel
+const (
+	accessRead      = 1 << 0
+	accessWrite     = 1 << 1
+	accessInfo      = 1 << 2
+	accessSuspended = 1 << 8
+)
+
+type keyInfo struct {
+	Key             string `json:"key"`
+	Tag             string `json:"channel_id"`
+	Permissions     int    `json:"permissions"`
+	DeprecatedUntil int64  `json:"deprecated_until"`
+}
+
+type keysResponse struct {
+	Tag  string    `json:"channel_id"`
+	Keys []keyInfo `json:"keys"`
+}
+
+func permissionsString(p int) string {
+	var names []string
+
+	for _, bit := range []struct {
+		flag int
+		name string
+	}{{accessRead, "listen"}, {accessWrite, "publish"}, {accessInfo, "info"}, {accessSuspended, "suspended"}} {
+		if p&bit.flag != 0 {
+			names = append(names, bit.name)
+		}
+	}
+
+	return strconv.Itoa(p) + " (" + strings.Join(names, ",") + ")"
+}
+
+func unixString(t int64) string {
+	if t == 0 {
+		return "-"
+	}
+
+	return time.Unix(t, 0).UTC().Format(time.RFC3339)
+}
+
+// subcommand parses the args of a command, which takes exactly want positional args
+func subcommand(name string, args []string, want int, define func(fs *flag.FlagSet)) ([]string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if define != nil {
+		define(fs)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if fs.NArg() != want {
+		return nil, fmt.Errorf("%s takes %d arguments, see limqctl -h", name, want)
+	}
+
+	return fs.Args(), nil
+}
+
+func (c *ctl) printKeys(raw []byte, keys []keyInfo) error {
+	if c.out.json {
+		return c.out.raw(raw)
+	}
+
+	rows := make([][]string, 0, len(keys))
+	for _, k := range keys {
+		rows = append(rows, []string{k.Tag, k.Key, permissionsString(k.Permissions), unixString(k.DeprecatedUntil)})
+	}
+
+	c.out.table([]string{"CHANNEL", "KEY", "PERMISSIONS", "DEPRECATED UNTIL"}, rows)
+	return nil
+}
+
+func channelsCommand(c *ctl, args []string) error {
+	if len(args) > 0 && args[0] == "create" {
+		var permissions int
+
+		if _, err := subcommand("channels create", args[1:], 0, func(fs *flag.FlagSet) {
+			fs.IntVar(&permissions, "permissions", accessRead|accessWrite, "permissions of the key")
+		}); err != nil {
+			return err
+		}
+
+		raw, err := c.admin.do(http.MethodPost, "/admin/channels", map[string]any{
+			"keys": []map[string]int{{"permissions": permissions}},
+		})
+		if err != nil {
+			return err
+		}
+
+		created := keysResponse{}
+		if err := json.Unmarshal(raw, &created); err != nil {
+			return err
+		}
+
+		return c.printKeys(raw, created.Keys)
+	}
+
+	if _, err := subcommand("channels", args, 0, nil); err != nil {
+		return err
+	}
+
+	list := struct {
+		Channels []struct {
+			Tag      string `json:"channel_id"`
+			Buffered int64  `json:"buffered"`
+		} `json:"channels"`
+	}{}
+
+	raw, err := c.admin.get("/admin/channels", &list)
+	if err != nil {
+		return err
+	}
+
+	if c.out.json {
+		return c.out.raw(raw)
+	}
+
+	rows := make([][]string, 0, len(list.Channels))
+	for _, ch := range list.Channels {
+		rows = append(rows, []string{ch.Tag, strconv.FormatInt(ch.Buffered, 10)})
+	}
+
+	c.out.table([]string{"CHANNEL", "BUFFERED"}, rows)
+	return nil
+}
+
+func keysCommand(c *ctl, args []string) error {
+	if len(args) == 0 {
+		return errors.New("keys needs a channel or a subcommand, see limqctl -h")
+	}
+
+	var (
+		raw []byte
+		err error
+	)
+
+	switch sub := args[0]; sub {
+	case "create":
+		var permissions int
+
+		rest, err := subcommand("keys create", args[1:], 1, func(fs *flag.FlagSet) {
+			fs.IntVar(&permissions, "permissions", accessRead, "permissions of the key")
+		})
+		if err != nil {
+			return err
+		}
+
+		raw, err = c.admin.do(http.MethodPost, "/admin/channels/"+url.PathEscape(rest[0])+"/keys",
+			map[string]int{"permissions": permissions})
+		if err != nil {
+			return err
+		}
+
+	case "suspend", "resume", "delete", "rotate":
+		var grace int
+
+		rest, err := subcommand("keys "+sub, args[1:], 1, func(fs *flag.FlagSet) {
+			if sub == "rotate" {
+				fs.IntVar(&grace, "grace", 0, "seconds the old key keeps working, the server's default if 0")
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		path := "/admin/keys/" + url.PathEscape(rest[0])
+
+		switch sub {
+		case "delete":
+			if _, err := c.admin.do(http.MethodDelete, path, nil); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(c.out.w, "deleted")
+			return nil
+
+		case "rotate":
+			path += "/rotate"
+			if grace > 0 {
+				path += "?grace=" + strconv.Itoa(grace)
+			}
+
+		default:
+			path += "/" + sub
+		}
+
+		raw, err = c.admin.do(http.MethodPost, path, nil)
+		if err != nil {
+			return err
+		}
+
+	default:
+		list := keysResponse{}
+
+		raw, err = c.admin.get("/admin/channels/"+url.PathEscape(sub)+"/keys", &list)
+		if err != nil {
+			return err
+		}
+
+		return c.printKeys(raw, list.Keys)
+	}
+
+	key := keyInfo{}
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return err
+	}
+
+	return c.printKeys(raw, []keyInfo{key})
+}
+
+func purgeCommand(c *ctl, args []string) error {
+	rest, err := subcommand("purge", args, 1, nil)
+	if err != nil {
+		return err
+	}
+
+	raw, err := c.admin.do(http.MethodDelete, "/admin/channels/"+url.PathEscape(rest[0])+"/messages", nil)
+	if err != nil {
+		return err
+	}
+
+	if c.out.json {
+		return c.out.raw(raw)
+	}
+
+	purged := struct {
+		Purged int64 `json:"purged"`
+	}{}
+
+	if err := json.Unmarshal(raw, &purged); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out.w, "%d buffered messages dropped\n", purged.Purged)
+	return nil
+}
+
+func exportCommand(c *ctl, args []string) error {
+	var file string
+
+	rest, err := subcommand("export", args, 1, func(fs *flag.FlagSet) {
+		fs.StringVar(&file, "f", "", "output file, stdout if empty")
+	})
+	if err != nil {
+		return err
+	}
+
+	raw, err := c.admin.do(http.MethodGet, "/admin/channels/"+url.PathEscape(rest[0])+"/messages", nil)
+	if err != nil {
+		return err
+	}
+
+	// the export is JSON whatever the output format is
+	out := c.out
+	out.json = true
+
+	if len(file) > 0 {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		out.w = f
+	}
+
+	return out.raw(raw)
+}
+
+func importCommand(c *ctl, args []string) error {
+	var file string
+
+	rest, err := subcommand("import", args, 1, func(fs *flag.FlagSet) {
+		fs.StringVar(&file, "f", "", "input file, stdin if empty")
+	})
+	if err != nil {
+		return err
+	}
+
+	in := c.stdin
+
+	if len(file) > 0 {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		in = f
+	}
+
+	raw, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	// only the messages of an export are sent, its channel is ignored
+	backlog := struct {
+		Messages []json.RawMessage `json:"messages"`
+	}{}
+
+	if err := json.Unmarshal(raw, &backlog); err != nil {
+		return fmt.Errorf("malformed export: %w", err)
+	}
+
+	raw, err = c.admin.do(http.MethodPost, "/admin/channels/"+url.PathEscape(rest[0])+"/messages", backlog)
+	if err != nil {
+		return err
+	}
+
+	if c.out.json {
+		return c.out.raw(raw)
+	}
+
+	imported := struct {
+		Imported int `json:"imported"`
+	}{}
+
+	if err := json.Unmarshal(raw, &imported); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out.w, "%d messages imported\n", imported.Imported)
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type forwardFilter struct {
+	Types   []string          `json:"types,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type edge struct {
+	Source      string         `json:"source"`
+	ID          string         `json:"id"`
+	Destination string         `json:"destination"`
+	Filter      *forwardFilter `json:"filter,omitempty"`
+	Scope       string         `json:"scope,omitempty"`
+
+	// Mixin marks the unfiltered forwards of the plain limq_mixin_<tag> list
+	Mixin bool `json:"mixin,omitempty"`
+}
+
+func (f *forwardFilter) String() string {
+	if f == nil {
+		return "*"
+	}
+
+	var parts []string
+
+	if len(f.Types) > 0 {
+		parts = append(parts, "type="+strings.Join(f.Types, "|"))
+	}
+
+	names := make([]string, 0, len(f.Headers))
+	for name := range f.Headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		parts = append(parts, name+"="+f.Headers[name])
+	}
+
+	if len(parts) == 0 {
+		return "*"
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// forwardGraph walks the mixins and the forwarding rules of the roots and of every channel they lead to
+func (c *ctl) forwardGraph(roots []string) ([]edge, error) {
+	var edges []edge
+
+	visited := map[string]bool{}
+	queue := append([]string{}, roots...)
+
+	for len(queue) > 0 {
+		tag := queue[0]
+		queue = queue[1:]
+
+		if visited[tag] {
+			continue
+		}
+
+		visited[tag] = true
+
+		forwards := struct {
+			Targets []string `json:"targets"`
+		}{}
+
+		if _, err := c.admin.get("/admin/channels/"+url.PathEscape(tag)+"/forwards", &forwards); err != nil {
+			return nil, err
+		}
+
+		for _, target := range forwards.Targets {
+			edges = append(edges, edge{Source: tag, Destination: target, Mixin: true})
+			queue = append(queue, target)
+		}
+
+		rules := struct {
+			Rules []edge `json:"rules"`
+		}{}
+
+		if _, err := c.admin.get("/admin/channels/"+url.PathEscape(tag)+"/rules", &rules); err != nil {
+			return nil, err
+		}
+
+		for _, r := range rules.Rules {
+			r.Source = tag
+			edges = append(edges, r)
+			queue = append(queue, r.Destination)
+		}
+	}
+
+	return edges, nil
+}
+
+func graphCommand(c *ctl, args []string) error {
+	if len(args) > 1 {
+		return errors.New("graph takes at most 1 argument, see limqctl -h")
+	}
+
+	roots := args
+
+	if len(roots) == 0 {
+		list := struct {
+			Channels []struct {
+				Tag string `json:"channel_id"`
+			} `json:"channels"`
+		}{}
+
+		if _, err := c.admin.get("/admin/channels", &list); err != nil {
+			return err
+		}
+
+		for _, ch := range list.Channels {
+			roots = append(roots, ch.Tag)
+		}
+	}
+
+	edges, err := c.forwardGraph(roots)
+	if err != nil {
+		return err
+	}
+
+	if c.out.json {
+		if edges == nil {
+			edges = []edge{}
+		}
+
+		return c.out.encode(struct {
+			Edges []edge `json:"edges"`
+		}{edges})
+	}
+
+	rows := make([][]string, 0, len(edges))
+	for _, e := range edges {
+		scope := e.Scope
+		if len(scope) == 0 {
+			scope = "-"
+		}
+
+		id := e.ID
+		if e.Mixin {
+			id = "(mixin)"
+		}
+
+		rows = append(rows, []string{e.Source, "->", e.Destination, id, e.Filter.String(), scope})
+	}
+
+	c.out.table([]string{"SOURCE", "", "DESTINATION", "RULE", "FILTER", "SCOPE"}, rows)
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func fakeAdmin(t *testing.T, routes map[string]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(apiError{Code: 1, StatusText: "admin token is missing or invalid"})
+
+			return
+		}
+
+		body, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+}
+
+func TestGraph(t *testing.T) {
+	const a, b, c, d = "aaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbb", "cccccccccccccccc", "dddddddddddddddd"
+
+	rules := func(r ...map[string]any) map[string]any { return map[string]any{"rules": r} }
+	forwards := func(targets ...string) map[string]any { return map[string]any{"targets": targets} }
+
+	srv := fakeAdmin(t, map[string]any{
+		"GET /admin/channels": map[string]any{"channels": []map[string]any{{"channel_id": a}, {"channel_id": c}}},
+		"GET /admin/channels/" + a + "/rules": rules(map[string]any{"id": "r1", "destination": b,
+			"filter": map[string]any{"types": []string{"text"}, "headers": map[string]string{"kind": "order"}}}),
+		"GET /admin/channels/" + b + "/rules": rules(map[string]any{"id": "r2", "destination": c, "scope": "one"}),
+		"GET /admin/channels/" + c + "/rules": rules(),
+		"GET /admin/channels/" + d + "/rules": rules(),
+
+		"GET /admin/channels/" + a + "/forwards": forwards(),
+		"GET /admin/channels/" + b + "/forwards": forwards(),
+		"GET /admin/channels/" + c + "/forwards": forwards(d),
+		"GET /admin/channels/" + d + "/forwards": forwards(),
+	})
+
+	defer srv.Close()
+
+	out := &bytes.Buffer{}
+	cli := &ctl{admin: newAdmin(srv.URL, "secret"), out: printer{w: out}}
+
+	if err := graphCommand(cli, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	table := out.String()
+	for _, want := range []string{"r1", "type=text kind=order", "r2", "one", d, "(mixin)"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("%q is missing in\n%s", want, table)
+		}
+	}
+
+	out.Reset()
+	cli.out.json = true
+
+	if err := graphCommand(cli, []string{b}); err != nil {
+		t.Fatal(err)
+	}
+
+	graph := struct {
+		Edges []edge `json:"edges"`
+	}{}
+
+	if err := json.Unmarshal(out.Bytes(), &graph); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(graph.Edges) != 2 || graph.Edges[0].Source != b || graph.Edges[0].Destination != c ||
+		graph.Edges[1].Source != c || graph.Edges[1].Destination != d || !graph.Edges[1].Mixin || graph.Edges[1].Filter != nil {
+		t.Errorf("only the forwards reachable from the channel are expected, got %+v", graph.Edges)
+	}
+
+	cli.admin.token = "wrong"
+
+	err := graphCommand(cli, nil)
+	if e, ok := err.(*apiError); !ok || e.HTTPStatus != http.StatusUnauthorized || e.StatusText != "admin token is missing or invalid" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestSubscribeURL(t *testing.T) {
+	u, err := subscribeURL("https://limq.example/api/", "key", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u != "wss://limq.example/api/subscribekey?channels=a%2Cb&envelope=1" {
+		t.Error(u)
+	}
+}
@@ -0,0 +1,100 @@
+// limqctl manages a limq deployment through its admin API
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `Usage: limqctl [flags] <command> [args]
+
+Commands:
+  channels                               list the channels with their buffered messages count
+  channels create [-permissions 3]       create a channel with a key
+  keys <channel>                         list the keys of a channel
+  keys create [-permissions 1] <channel> issue a key for a channel
+  keys suspend|resume|delete <key>
+  keys rotate [-grace 86400] <key>       issue a successor key, the key works for the grace seconds
+  graph [channel]                        show the mixins and forwarding rules, only the ones reachable from channel if given
+  purge <channel>                        drop the buffered messages
+  export [-f file] <channel>             write the buffered messages as JSON, to stdout by default
+  import [-f file] <channel>             buffer the exported messages, read from stdin by default
+  tail -key <access key> [channel...]    print the messages delivered to the key, the messages are consumed
+
+Flags:
+`
+
+// ctl is the state shared by the commands
+type ctl struct {
+	admin *admin
+	out   printer
+
+	// base is the API address, used by tail
+	base  string
+	stdin io.Reader
+}
+
+type command func(c *ctl, args []string) error
+
+var commands = map[string]command{
+	"channels": channelsCommand,
+	"keys":     keysCommand,
+	"graph":    graphCommand,
+	"purge":    purgeCommand,
+	"export":   exportCommand,
+	"import":   importCommand,
+	"tail":     tailCommand,
+}
+
+func envOrDefault(key string, fallback string) string {
+	if env := os.Getenv(key); len(env) > 0 {
+		return env
+	}
+
+	return fallback
+}
+
+func main() {
+	fs := flag.NewFlagSet("limqctl", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	address := fs.String("addr", envOrDefault("LIMQ_ADDRESS", "http://localhost:8081"), "limq API address (env LIMQ_ADDRESS)")
+	token := fs.String("token", os.Getenv("LIMQ_ADMIN_TOKEN"), "admin token (env LIMQ_ADMIN_TOKEN)")
+	format := fs.String("o", "table", "output format: table or json")
+
+	_ = fs.Parse(os.Args[1:])
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "limqctl: unknown output format %q\n", *format)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "limqctl: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c := &ctl{
+		admin: newAdmin(*address, *token),
+		out:   printer{w: os.Stdout, json: *format == "json"},
+		base:  *address,
+		stdin: os.Stdin,
+	}
+
+	if err := cmd(c, fs.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "limqctl:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer writes the results as a table or as JSON
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func (p printer) table(header []string, rows [][]string) {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	tw.Flush()
+}
+
+// raw prints a JSON document as is, indented
+func (p printer) raw(doc []byte) error {
+	out := bytes.Buffer{}
+	if err := json.Indent(&out, bytes.TrimSpace(doc), "", "    "); err != nil {
+		return err
+	}
+
+	out.WriteByte('\n')
+
+	_, err := p.w.Write(out.Bytes())
+	return err
+}
+
+func (p printer) encode(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "    ")
+
+	return enc.Encode(v)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/fasthttp/websocket"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// tailed is the envelope of a message delivered over /subscribe
+type tailed struct {
+	Channel string            `json:"channel_id"`
+	Type    string            `json:"type"`
+	Scope   string            `json:"scope"`
+	Headers map[string]string `json:"headers"`
+	Origin  string            `json:"origin"`
+	Text    *string           `json:"text"`
+	Data    []byte            `json:"data"`
+}
+
+func (t tailed) row() []string {
+	payload := fmt.Sprintf("<%d bytes>", len(t.Data))
+	if t.Text != nil {
+		payload = *t.Text
+	}
+
+	origin := ""
+	if len(t.Origin) > 0 {
+		origin = " from " + t.Origin
+	}
+
+	return []string{time.Now().Format("15:04:05.000"), t.Channel + origin, t.Type, t.Scope, payload}
+}
+
+// subscribeURL converts the API address into the /subscribe WebSocket URL of the key
+func subscribeURL(base, key string, channels []string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+
+	case "https":
+		u.Scheme = "wss"
+
+	default:
+		return "", fmt.Errorf("unsupported address scheme %q", u.Scheme)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/subscribe" + key
+
+	q := url.Values{"envelope": {"1"}}
+	if len(channels) > 0 {
+		q.Set("channels", strings.Join(channels, ","))
+	}
+
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+func tailCommand(c *ctl, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	key := fs.String("key", os.Getenv("LIMQ_ACCESS_KEY"), "access key with listen permissions (env LIMQ_ACCESS_KEY)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*key) == 0 {
+		return errors.New("tail needs an access key, see limqctl -h")
+	}
+
+	address, err := subscribeURL(c.base, *key, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(address, nil)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+
+	go func() {
+		<-interrupted
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	}()
+
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+
+			return err
+		}
+
+		if c.out.json {
+			fmt.Fprintln(c.out.w, strings.TrimSpace(string(frame)))
+			continue
+		}
+
+		t := tailed{}
+		if err := json.Unmarshal(frame, &t); err != nil {
+			return fmt.Errorf("malformed frame: %w", err)
+		}
+
+		fmt.Fprintln(c.out.w, strings.Join(t.row(), "  "))
+	}
+}
@@ -3,4 +3,14 @@ package common
 const (
 	ChannelDescriptor   = `limq_isolate_`
 	ForwardToDescriptor = `limq_mixin_`
+	// ForwardRules holds a JSON array of the structured forwarding rules of a channel
+	ForwardRules = `limq_rules_`
+
+	// ChannelKeys indexes access keys issued through the admin API by channel tag
+	ChannelKeys = `limq_keys_`
+	// Channels is a set of the channel tags created through the admin API
+	Channels = `limq_channels`
+
+	// InvalidationChannel is a pub/sub channel announcing descriptor and mixin changes
+	InvalidationChannel = `limq_invalidate`
 )
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+	"io"
+	"limq/idempotency"
+	"limq/quota"
+	"limq/tracing"
+	"os"
+	"time"
+)
+
+// FileEnv names the config file when there is no -config flag
+const FileEnv = "LIMQ_CONFIG"
+
+type Redis struct {
+	Address  string `yaml:"address"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+type Auth struct {
+	Backend     string        `yaml:"backend"`
+	CacheTTL    time.Duration `yaml:"cache_ttl"`
+	File        string        `yaml:"file"`
+	JWKSFile    string        `yaml:"jwks_file"`
+	JWTIssuer   string        `yaml:"jwt_issuer"`
+	JWTAudience string        `yaml:"jwt_audience"`
+	TokenSecret string        `yaml:"token_secret"`
+}
+
+type CORS struct {
+	Origins     []string `yaml:"origins"`
+	Credentials bool     `yaml:"credentials"`
+}
+
+type Audit struct {
+	LogPath string `yaml:"log_path"`
+	DB      bool   `yaml:"db"`
+	Publish bool   `yaml:"publish"`
+}
+
+type Tracing struct {
+	Exporter     string `yaml:"exporter"`
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+}
+
+type TLS struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	ClientAuth   string `yaml:"client_auth"`
+	ClientMap    string `yaml:"client_map"`
+}
+
+// Enabled reports whether the API is served over TLS
+func (t TLS) Enabled() bool {
+	return len(t.CertFile) > 0
+}
+
+type Metrics struct {
+	Address    string `yaml:"address"`
+	PerChannel bool   `yaml:"per_channel"`
+}
+
+// Config is the whole limq configuration.
+// Every setting comes from, in increasing priority: the defaults, the env, the config file and the flags
+type Config struct {
+	Address         string        `yaml:"address"`
+	Debug           bool          `yaml:"debug"`
+	LogLevel        string        `yaml:"log_level"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	AdminToken      string        `yaml:"admin_token"`
+	DatabaseURL     string        `yaml:"database_url"`
+	DBTimeout       time.Duration `yaml:"db_timeout"`
+	ListenerPolicy  string        `yaml:"listener_policy"`
+	MaxForwardHops  int           `yaml:"max_forward_hops"`
+	Standalone      bool          `yaml:"standalone"`
+
+	IdempotencyWindow time.Duration `yaml:"idempotency_window"`
+
+	TLS        TLS          `yaml:"tls"`
+	Redis      Redis        `yaml:"redis"`
+	Auth       Auth         `yaml:"auth"`
+	CORS       CORS         `yaml:"cors"`
+	RateLimits quota.Limits `yaml:"rate_limits"`
+	Quotas     quota.Quotas `yaml:"quotas"`
+	Audit      Audit        `yaml:"audit"`
+	Tracing    Tracing      `yaml:"tracing"`
+	Metrics    Metrics      `yaml:"metrics"`
+}
+
+func Defaults() Config {
+	return Config{
+		Address:         ":8081",
+		ShutdownTimeout: 15 * time.Second,
+		DBTimeout:       1 * time.Second,
+
+		IdempotencyWindow: idempotency.DefaultWindow,
+
+		Redis:   Redis{Address: "localhost:6379", DB: 3},
+		Auth:    Auth{Backend: "redis", CacheTTL: 5 * time.Second, File: "limq-keys.yaml"},
+		CORS:    CORS{Origins: []string{"*"}},
+		Quotas:  quota.Defaults,
+		Tracing: Tracing{OTLPEndpoint: tracing.DefaultOTLPEndpoint},
+	}
+}
+
+// Load builds the configuration from the env, the config file and the command-line args
+func Load(args []string) (Config, error) {
+	c := Defaults()
+
+	fs := flag.NewFlagSet("limq", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(FileEnv), "YAML config file (env "+FileEnv+")")
+
+	flagged := map[string]string{}
+
+	for _, s := range settings {
+		name := s.name
+		fs.Func(name, s.usage+" (env "+s.env+")", func(value string) error {
+			flagged[name] = value
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	for _, s := range settings {
+		if value := os.Getenv(s.env); len(value) > 0 {
+			if err := s.setEnv(&c, value); err != nil {
+				return Config{}, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	if len(*path) > 0 {
+		if err := c.readFile(*path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := flagged[s.name]; ok {
+			if err := s.set(&c, value); err != nil {
+				return Config{}, fmt.Errorf("-%s: %w", s.name, err)
+			}
+		}
+	}
+
+	return c, c.validate()
+}
+
+func (c *Config) readFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Level is the log level, debug in the debug mode unless set explicitly
+func (c Config) Level() (zapcore.Level, error) {
+	if len(c.LogLevel) == 0 {
+		if c.Debug {
+			return zapcore.DebugLevel, nil
+		}
+
+		return zapcore.InfoLevel, nil
+	}
+
+	return zapcore.ParseLevel(c.LogLevel)
+}
+
+func (c Config) validate() error {
+	if _, err := c.Level(); err != nil {
+		return err
+	}
+
+	q := c.Quotas
+	if q.MaxMessageSize <= 0 || q.MaxBufferedMessages <= 0 || q.MaxMessageHeaders <= 0 || q.MaxHeadersSize <= 0 || q.ListenTimeout <= 0 {
+		return errors.New("quotas must be positive")
+	}
+
+	if (len(c.TLS.CertFile) == 0) != (len(c.TLS.KeyFile) == 0) {
+		return errors.New("tls.cert_file and tls.key_file must be set together")
+	}
+
+	if len(c.TLS.ClientCAFile) > 0 && !c.TLS.Enabled() {
+		return errors.New("tls.client_ca_file needs tls.cert_file")
+	}
+
+	if len(c.TLS.ClientMap) > 0 && len(c.TLS.ClientCAFile) == 0 {
+		return errors.New("tls.client_map needs tls.client_ca_file")
+	}
+
+	if c.CORS.Credentials {
+		for _, origin := range c.CORS.Origins {
+			if origin == "*" {
+				return errors.New(`cors.credentials can't be enabled for the "*" origin, list the origins explicitly`)
+			}
+		}
+	}
+
+	if c.Standalone {
+		if c.Auth.Backend != "file" && c.Auth.Backend != "jwt" {
+			return errors.New("standalone mode needs the file or jwt auth.backend")
+		}
+
+		if c.Audit.DB {
+			return errors.New("audit.db is not available in standalone mode")
+		}
+	}
+
+	if c.DBTimeout <= 0 || c.ShutdownTimeout <= 0 {
+		return errors.New("timeouts must be positive")
+	}
+
+	if c.IdempotencyWindow < 0 {
+		return errors.New("idempotency_window must not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "limq.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+address: ":9000"
+redis:
+  db: 5
+cors:
+  origins: [https://a.example]
+quotas:
+  listen_timeout: 10s
+`)
+
+	t.Setenv("ADDRESS", ":7000")
+	t.Setenv("REDIS", "redis:6379")
+	t.Setenv("REDIS_DB", "4")
+
+	c, err := Load([]string{"-config", path, "-redis.db", "6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Address != ":9000" {
+		t.Error("the file must override the env", c.Address)
+	}
+
+	if c.Redis.Address != "redis:6379" {
+		t.Error("the env must override the defaults", c.Redis.Address)
+	}
+
+	if c.Redis.DB != 6 {
+		t.Error("the flags must override the file", c.Redis.DB)
+	}
+
+	if len(c.CORS.Origins) != 1 || c.Quotas.ListenTimeout != 10*time.Second {
+		t.Error("the file is not applied", c.CORS.Origins, c.Quotas.ListenTimeout)
+	}
+
+	if c.Quotas.MaxMessageSize != Defaults().Quotas.MaxMessageSize {
+		t.Error("the settings missing in the file must keep the defaults", c.Quotas.MaxMessageSize)
+	}
+}
+
+func TestLoadLegacyDebug(t *testing.T) {
+	for _, value := range []string{"yes", "on", "1"} {
+		t.Setenv("DEBUG", value)
+
+		c, err := Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !c.Debug {
+			t.Errorf("DEBUG=%s must turn the debug logging on", value)
+		}
+	}
+
+	t.Setenv("DEBUG", "false")
+
+	if c, err := Load(nil); err != nil || c.Debug {
+		t.Error("DEBUG=false must keep the debug logging off", err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	cases := []string{
+		"unknown: 1\n",
+		"log_level: loud\n",
+		"quotas:\n  max_message_size: -1\n",
+		"standalone: true\n",
+		"cors:\n  origins: [\"https://a.example\", \"*\"]\n  credentials: true\n",
+		"standalone: true\nauth:\n  backend: file\naudit:\n  db: true\n",
+	}
+
+	for _, content := range cases {
+		if _, err := Load([]string{"-config", writeConfig(t, content)}); err == nil {
+			t.Errorf("%q must be rejected", content)
+		}
+	}
+
+	t.Setenv("RATE_MESSAGES", "many")
+
+	if _, err := Load(nil); err == nil {
+		t.Error("a malformed env var must be rejected")
+	}
+}
+
+func TestReload(t *testing.T) {
+	current := Defaults()
+
+	next := current
+	next.LogLevel = "warn"
+	next.CORS.Origins = []string{"https://a.example"}
+	next.Redis.Address = "elsewhere:6379"
+
+	applied, report := current.Reload(next)
+
+	if len(report.Applied) != 2 || report.Applied[0] != "log_level" || report.Applied[1] != "cors.origins" {
+		t.Error("applied", report.Applied)
+	}
+
+	if len(report.RestartRequired) != 1 || report.RestartRequired[0] != "redis.address" {
+		t.Error("restart required", report.RestartRequired)
+	}
+
+	if applied.LogLevel != "warn" || applied.CORS.Origins[0] != "https://a.example" {
+		t.Error("reloadable settings are not applied", applied)
+	}
+
+	if applied.Redis.Address != current.Redis.Address {
+		t.Error("a setting which needs a restart must keep its value", applied.Redis.Address)
+	}
+
+	if _, report := applied.Reload(next); len(report.Applied) != 0 || len(report.RestartRequired) != 1 {
+		t.Error("second reload", report)
+	}
+}
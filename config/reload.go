@@ -0,0 +1,33 @@
+package config
+
+import "reflect"
+
+// Report lists the settings changed by a reload
+type Report struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// Reload returns the configuration to run with after next is loaded:
+// the reloadable settings are taken from next, the rest keep their values until a restart.
+// The settings which need a restart are reported on every reload until then
+func (c Config) Reload(next Config) (Config, Report) {
+	report := Report{Applied: []string{}, RestartRequired: []string{}}
+
+	for _, s := range settings {
+		current, updated := s.value(&c), s.value(&next)
+		if reflect.DeepEqual(current.Interface(), updated.Interface()) {
+			continue
+		}
+
+		if !s.reloadable {
+			report.RestartRequired = append(report.RestartRequired, s.name)
+			continue
+		}
+
+		current.Set(updated)
+		report.Applied = append(report.Applied, s.name)
+	}
+
+	return c, report
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting binds a Config field to its env var and flag
+type setting struct {
+	// name is the field's path in the config file, also the flag name
+	name  string
+	env   string
+	usage string
+
+	// reloadable settings are applied on reload, the rest need a restart
+	reloadable bool
+
+	// anyValueEnv bools are turned on by any env value which is not a bool, e.g. DEBUG=yes,
+	// the way the env was read before the config file existed
+	anyValueEnv bool
+
+	field func(c *Config) any
+}
+
+var settings = []setting{
+	{name: "address", env: "ADDRESS", usage: "API listen address",
+		field: func(c *Config) any { return &c.Address }},
+	{name: "debug", env: "DEBUG", usage: "development logging", anyValueEnv: true,
+		field: func(c *Config) any { return &c.Debug }},
+	{name: "log_level", env: "LOG_LEVEL", usage: "log level", reloadable: true,
+		field: func(c *Config) any { return &c.LogLevel }},
+	{name: "shutdown_timeout", env: "SHUTDOWN_TIMEOUT", usage: "drain deadline",
+		field: func(c *Config) any { return &c.ShutdownTimeout }},
+	{name: "admin_token", env: "ADMIN_TOKEN", usage: "admin API bearer token, the admin API is disabled if empty",
+		field: func(c *Config) any { return &c.AdminToken }},
+	{name: "database_url", env: "DATABASE_URL", usage: "PostgreSQL connection string",
+		field: func(c *Config) any { return &c.DatabaseURL }},
+	{name: "db_timeout", env: "DB_TIMEOUT", usage: "PostgreSQL operation timeout",
+		field: func(c *Config) any { return &c.DBTimeout }},
+	{name: "listener_policy", env: "LISTENER_POLICY", usage: "default listener policy",
+		field: func(c *Config) any { return &c.ListenerPolicy }},
+	{name: "max_forward_hops", env: "MAX_FORWARD_HOPS", usage: "forwards a message may go through",
+		field: func(c *Config) any { return &c.MaxForwardHops }},
+	{name: "standalone", env: "STANDALONE", usage: "serve without Redis and PostgreSQL, keeping everything in memory",
+		field: func(c *Config) any { return &c.Standalone }},
+	{name: "idempotency_window", env: "IDEMPOTENCY_WINDOW", usage: "time the X-Idempotency-Key of a publish is remembered for, 0 disables",
+		field: func(c *Config) any { return &c.IdempotencyWindow }},
+
+	{name: "tls.cert_file", env: "TLS_CERT_FILE", usage: "PEM certificate, the API is served over TLS if set; reloaded on change",
+		field: func(c *Config) any { return &c.TLS.CertFile }},
+	{name: "tls.key_file", env: "TLS_KEY_FILE", usage: "PEM private key of the certificate",
+		field: func(c *Config) any { return &c.TLS.KeyFile }},
+	{name: "tls.client_ca_file", env: "TLS_CLIENT_CA_FILE", usage: "PEM CAs of the client certificates, enables mTLS",
+		field: func(c *Config) any { return &c.TLS.ClientCAFile }},
+	{name: "tls.client_auth", env: "TLS_CLIENT_AUTH", usage: "none, optional or require",
+		field: func(c *Config) any { return &c.TLS.ClientAuth }},
+	{name: "tls.client_map", env: "TLS_CLIENT_MAP", usage: "file mapping the client certificates onto channels",
+		field: func(c *Config) any { return &c.TLS.ClientMap }},
+
+	{name: "redis.address", env: "REDIS", usage: "Redis address",
+		field: func(c *Config) any { return &c.Redis.Address }},
+	{name: "redis.password", env: "REDIS_PASSWORD", usage: "Redis password",
+		field: func(c *Config) any { return &c.Redis.Password }},
+	{name: "redis.db", env: "REDIS_DB", usage: "Redis database",
+		field: func(c *Config) any { return &c.Redis.DB }},
+
+	{name: "auth.backend", env: "AUTH_BACKEND", usage: "redis, file, postgres or jwt",
+		field: func(c *Config) any { return &c.Auth.Backend }},
+	{name: "auth.cache_ttl", env: "AUTH_CACHE_TTL", usage: "descriptor cache TTL of the redis backend",
+		field: func(c *Config) any { return &c.Auth.CacheTTL }},
+	{name: "auth.file", env: "AUTH_FILE", usage: "keys file of the file backend",
+		field: func(c *Config) any { return &c.Auth.File }},
+	{name: "auth.jwks_file", env: "JWKS_FILE", usage: "JWKS file of the jwt backend",
+		field: func(c *Config) any { return &c.Auth.JWKSFile }},
+	{name: "auth.jwt_issuer", env: "JWT_ISSUER", usage: "expected JWT issuer",
+		field: func(c *Config) any { return &c.Auth.JWTIssuer }},
+	{name: "auth.jwt_audience", env: "JWT_AUDIENCE", usage: "expected JWT audience",
+		field: func(c *Config) any { return &c.Auth.JWTAudience }},
+	{name: "auth.token_secret", env: "TOKEN_SECRET", usage: "signed tokens secret, tokens are disabled if empty",
+		field: func(c *Config) any { return &c.Auth.TokenSecret }},
+
+	{name: "cors.origins", env: "CORS_ORIGINS", usage: "comma-separated allowed origins", reloadable: true,
+		field: func(c *Config) any { return &c.CORS.Origins }},
+	{name: "cors.credentials", env: "CORS_CREDENTIALS", usage: "allow credentials", reloadable: true,
+		field: func(c *Config) any { return &c.CORS.Credentials }},
+
+	{name: "rate_limits.messages_per_second", env: "RATE_MESSAGES", usage: "default published messages per second", reloadable: true,
+		field: func(c *Config) any { return &c.RateLimits.MessagesPerSecond }},
+	{name: "rate_limits.bytes_per_second", env: "RATE_BYTES", usage: "default published bytes per second", reloadable: true,
+		field: func(c *Config) any { return &c.RateLimits.BytesPerSecond }},
+	{name: "rate_limits.concurrent", env: "RATE_CONCURRENT", usage: "default concurrent requests", reloadable: true,
+		field: func(c *Config) any { return &c.RateLimits.Concurrent }},
+
+	{name: "quotas.max_message_size", env: "MAX_MESSAGE_SIZE", usage: "message payload limit in bytes", reloadable: true,
+		field: func(c *Config) any { return &c.Quotas.MaxMessageSize }},
+	{name: "quotas.max_buffered_messages", env: "MAX_BUFFERED_MESSAGES", usage: "buffered messages per channel", reloadable: true,
+		field: func(c *Config) any { return &c.Quotas.MaxBufferedMessages }},
+	{name: "quotas.max_message_headers", env: "MAX_MESSAGE_HEADERS", usage: "user headers per message", reloadable: true,
+		field: func(c *Config) any { return &c.Quotas.MaxMessageHeaders }},
+	{name: "quotas.max_headers_size", env: "MAX_HEADERS_SIZE", usage: "user headers limit in bytes", reloadable: true,
+		field: func(c *Config) any { return &c.Quotas.MaxHeadersSize }},
+	{name: "quotas.listen_timeout", env: "LISTEN_TIMEOUT", usage: "long poll timeout without X-Timeout", reloadable: true,
+		field: func(c *Config) any { return &c.Quotas.ListenTimeout }},
+
+	{name: "audit.log_path", env: "AUDIT_LOG_PATH", usage: "audit log file, the main log if empty",
+		field: func(c *Config) any { return &c.Audit.LogPath }},
+	{name: "audit.db", env: "AUDIT_DB", usage: "store the audit events in PostgreSQL",
+		field: func(c *Config) any { return &c.Audit.DB }},
+	{name: "audit.publish", env: "AUDIT_PUBLISH", usage: "audit every publish",
+		field: func(c *Config) any { return &c.Audit.Publish }},
+
+	{name: "tracing.exporter", env: "TRACING_EXPORTER", usage: "none, stdout or otlp",
+		field: func(c *Config) any { return &c.Tracing.Exporter }},
+	{name: "tracing.otlp_endpoint", env: "OTLP_ENDPOINT", usage: "OTLP/HTTP traces endpoint",
+		field: func(c *Config) any { return &c.Tracing.OTLPEndpoint }},
+
+	{name: "metrics.address", env: "METRICS_ADDRESS", usage: "metrics listen address, metrics are disabled if empty",
+		field: func(c *Config) any { return &c.Metrics.Address }},
+	{name: "metrics.per_channel", env: "METRICS_PER_CHANNEL", usage: "per channel metrics",
+		field: func(c *Config) any { return &c.Metrics.PerChannel }},
+}
+
+func (s setting) set(c *Config, raw string) (err error) {
+	switch p := s.field(c).(type) {
+	case *string:
+		*p = raw
+
+	case *bool:
+		*p, err = strconv.ParseBool(raw)
+
+	case *int:
+		*p, err = strconv.Atoi(raw)
+
+	case *float64:
+		*p, err = strconv.ParseFloat(raw, 64)
+
+	case *time.Duration:
+		*p, err = time.ParseDuration(raw)
+
+	case *[]string:
+		*p = splitList(raw)
+	}
+
+	return err
+}
+
+// setEnv applies a non-empty env value
+func (s setting) setEnv(c *Config, raw string) error {
+	err := s.set(c, raw)
+	if err != nil && s.anyValueEnv {
+		return s.set(c, "true")
+	}
+
+	return err
+}
+
+func (s setting) value(c *Config) reflect.Value {
+	return reflect.ValueOf(s.field(c)).Elem()
+}
+
+func splitList(raw string) []string {
+	var list []string
+
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,78 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the period of dropping the expired keys
+const sweepEvery = time.Minute
+
+type entry struct {
+	state   State
+	expires time.Time
+}
+
+// Local is the single replica Store, it keeps the keys in the process' memory
+type Local struct {
+	mu    *sync.Mutex
+	keys  map[string]entry
+	swept time.Time
+}
+
+func NewLocal() *Local {
+	return &Local{mu: &sync.Mutex{}, keys: map[string]entry{}, swept: time.Now()}
+}
+
+func localKey(tag, key string) string {
+	return tag + "_" + key
+}
+
+func (l *Local) Claim(_ context.Context, tag, key string) (State, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	if e, ok := l.keys[localKey(tag, key)]; ok && now.Before(e.expires) {
+		return e.state, nil
+	}
+
+	l.keys[localKey(tag, key)] = entry{state: Pending, expires: now.Add(claimTTL)}
+	return Claimed, nil
+}
+
+func (l *Local) Complete(_ context.Context, tag, key string, window time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.keys[localKey(tag, key)] = entry{state: Completed, expires: time.Now().Add(window)}
+	return nil
+}
+
+func (l *Local) Release(_ context.Context, tag, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.keys[localKey(tag, key)].state == Pending {
+		delete(l.keys, localKey(tag, key))
+	}
+
+	return nil
+}
+
+func (l *Local) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepEvery {
+		return
+	}
+
+	l.swept = now
+
+	for key, e := range l.keys {
+		if !now.Before(e.expires) {
+			delete(l.keys, key)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLocal(t *testing.T) {
+	s := NewLocal()
+	ctx := context.Background()
+
+	if state, _ := s.Claim(ctx, "a", "k"); state != Claimed {
+		t.Fatal("first claim", state)
+	}
+
+	if state, _ := s.Claim(ctx, "a", "k"); state != Pending {
+		t.Error("claim in progress", state)
+	}
+
+	if state, _ := s.Claim(ctx, "b", "k"); state != Claimed {
+		t.Error("keys must be scoped by channel", state)
+	}
+
+	_ = s.Release(ctx, "a", "k")
+
+	if state, _ := s.Claim(ctx, "a", "k"); state != Claimed {
+		t.Error("released key must be claimable", state)
+	}
+
+	_ = s.Complete(ctx, "a", "k", time.Hour)
+	_ = s.Release(ctx, "a", "k")
+
+	if state, _ := s.Claim(ctx, "a", "k"); state != Completed {
+		t.Error("completed key must be kept", state)
+	}
+
+	_ = s.Complete(ctx, "b", "k", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if state, _ := s.Claim(ctx, "b", "k"); state != Claimed {
+		t.Error("expired key must be claimable", state)
+	}
+}
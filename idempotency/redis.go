@@ -0,0 +1,73 @@
+package idempotency
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+const keysPrefix = `limq_idem_`
+
+const (
+	valuePending   = "pending"
+	valueCompleted = "completed"
+)
+
+// claimScript returns the current value of the key, setting it to pending if there is none
+var claimScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	return v
+end
+
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return ''
+`)
+
+// releaseScript drops the key unless it's completed
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+end
+
+return 0
+`)
+
+// Redis keeps the keys in Redis, so that the retries are deduplicated across replicas
+type Redis struct {
+	c *redis.Client
+}
+
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{c: client}
+}
+
+func redisKey(tag, key string) string {
+	return keysPrefix + tag + "_" + key
+}
+
+func (r *Redis) Claim(ctx context.Context, tag, key string) (State, error) {
+	value, err := claimScript.Run(ctx, r.c, []string{redisKey(tag, key)}, valuePending, claimTTL.Milliseconds()).Text()
+	if err != nil {
+		return Claimed, err
+	}
+
+	switch value {
+	case "":
+		return Claimed, nil
+
+	case valueCompleted:
+		return Completed, nil
+
+	default:
+		return Pending, nil
+	}
+}
+
+func (r *Redis) Complete(ctx context.Context, tag, key string, window time.Duration) error {
+	return r.c.Set(ctx, redisKey(tag, key), valueCompleted, window).Err()
+}
+
+func (r *Redis) Release(ctx context.Context, tag, key string) error {
+	return releaseScript.Run(ctx, r.c, []string{redisKey(tag, key)}, valuePending).Err()
+}
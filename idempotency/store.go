@@ -0,0 +1,34 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultWindow is the default time the publishes are remembered for
+const DefaultWindow = 10 * time.Minute
+
+// claimTTL bounds the lifetime of the claims left by crashed replicas
+const claimTTL = 30 * time.Second
+
+// State is the outcome of a Claim
+type State int
+
+const (
+	// Claimed lets the caller publish, then Complete or Release the key
+	Claimed State = iota
+	// Pending means another publish with the key is in progress
+	Pending
+	// Completed means the key has been published within the window
+	Completed
+)
+
+// Store remembers the idempotency keys of the publishes, per channel
+type Store interface {
+	// Claim reserves the key of the channel unless it's claimed or completed already
+	Claim(ctx context.Context, tag, key string) (State, error)
+	// Complete remembers the claimed key as published for window
+	Complete(ctx context.Context, tag, key string, window time.Duration) error
+	// Release drops the claim of a failed publish, so that a retry may go through
+	Release(ctx context.Context, tag, key string) error
+}
@@ -0,0 +1,80 @@
+package listeners
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ModeExclusive lets only one listener per key, the newer ones are rejected
+	ModeExclusive = "exclusive"
+	// ModeTakeover lets only one listener per key, the newest one kicks the old one
+	ModeTakeover = "takeover"
+	// ModeShared lets up to Policy.Max listeners per key
+	ModeShared = "shared"
+)
+
+// Policy controls concurrent listeners of a single access key.
+// The zero Policy is "not set"
+type Policy struct {
+	Mode string
+	Max  int
+}
+
+var Exclusive = Policy{Mode: ModeExclusive, Max: 1}
+
+// ParsePolicy parses "exclusive", "takeover" or "shared:N"
+func ParsePolicy(raw string) (Policy, error) {
+	mode, max, _ := strings.Cut(strings.ToLower(strings.TrimSpace(raw)), ":")
+
+	switch mode {
+	case "":
+		return Policy{}, nil
+
+	case ModeExclusive, ModeTakeover:
+		return Policy{Mode: mode, Max: 1}, nil
+
+	case ModeShared:
+		n, err := strconv.Atoi(max)
+		if err != nil || n < 1 {
+			return Policy{}, fmt.Errorf("listener policy %q: shared mode needs a positive limit, e.g. shared:4", raw)
+		}
+
+		return Policy{Mode: mode, Max: n}, nil
+
+	default:
+		return Policy{}, fmt.Errorf("unknown listener policy %q", raw)
+	}
+}
+
+func (p Policy) String() string {
+	if p.Mode == ModeShared {
+		return ModeShared + ":" + strconv.Itoa(p.Max)
+	}
+
+	return p.Mode
+}
+
+// Resolve falls back to the default if the policy is not set
+func (p Policy) Resolve(fallback Policy) Policy {
+	if len(p.Mode) == 0 {
+		return fallback
+	}
+
+	return p
+}
+
+func (p Policy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Policy) UnmarshalText(text []byte) error {
+	parsed, err := ParsePolicy(string(text))
+	if err != nil {
+		return err
+	}
+
+	*p = parsed
+	return nil
+}
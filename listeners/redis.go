@@ -0,0 +1,179 @@
+package listeners
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	leasesPrefix = `limq_listeners_`
+	kickChannel  = `limq_listeners_kick`
+
+	// leaseTTL bounds the lifetime of the leases left by crashed replicas,
+	// live leases are prolonged in background
+	leaseTTL = 30 * time.Second
+)
+
+// acquireScript returns {0} if the limit is reached, otherwise {1, evicted lease ids...}
+var acquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+
+local result = {1}
+
+if ARGV[4] == 'takeover' then
+	for _, id in ipairs(redis.call('ZRANGE', KEYS[1], 0, -1)) do
+		table.insert(result, id)
+	end
+
+	redis.call('DEL', KEYS[1])
+elseif redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[5]) then
+	return {0}
+end
+
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[2]), ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return result
+`)
+
+// refreshScript prolongs a lease unless it's gone (taken over or expired)
+var refreshScript = redis.NewScript(`
+if not redis.call('ZSCORE', KEYS[1], ARGV[3]) then
+	return 0
+end
+
+redis.call('ZADD', KEYS[1], tonumber(ARGV[1]) + tonumber(ARGV[2]), ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// Redis tracks the listeners of all the replicas.
+// Takeovers are announced to the replica holding the old connection over pub/sub.
+// Redis failures don't block the listeners
+type Redis struct {
+	c *redis.Client
+
+	mu    *sync.Mutex
+	local map[string]*lease
+}
+
+// NewRedis creates the registry and starts listening to the takeover announcements until ctx is done
+func NewRedis(ctx context.Context, client *redis.Client) *Redis {
+	r := &Redis{c: client, mu: &sync.Mutex{}, local: map[string]*lease{}}
+
+	go r.watchKicks(ctx)
+
+	return r
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func (r *Redis) Acquire(ctx context.Context, key string, p Policy) (Lease, error) {
+	l := newLease()
+	leases := leasesPrefix + key
+
+	result, err := acquireScript.Run(ctx, r.c, []string{leases},
+		nowMillis(), leaseTTL.Milliseconds(), l.id, p.Mode, p.Max).Slice()
+
+	if err != nil {
+		zap.L().Warn("listeners registry is unavailable", zap.Error(err))
+
+		l.onRelease = func() {}
+		return l, nil
+	}
+
+	if ok, _ := result[0].(int64); ok != 1 {
+		return nil, ErrBusy
+	}
+
+	if len(result) > 1 {
+		evicted := make([]string, 0, len(result)-1)
+		for _, id := range result[1:] {
+			if s, ok := id.(string); ok {
+				evicted = append(evicted, s)
+			}
+		}
+
+		if err := r.c.Publish(ctx, kickChannel, strings.Join(evicted, ",")).Err(); err != nil {
+			zap.L().Warn("unable to announce a listener takeover", zap.Error(err))
+		}
+	}
+
+	done := make(chan struct{})
+
+	r.mu.Lock()
+	r.local[l.id] = l
+	r.mu.Unlock()
+
+	l.onRelease = func() {
+		close(done)
+
+		r.mu.Lock()
+		delete(r.local, l.id)
+		r.mu.Unlock()
+
+		r.c.ZRem(context.Background(), leases, l.id)
+	}
+
+	go r.keepAlive(leases, l, done)
+
+	return l, nil
+}
+
+func (r *Redis) keepAlive(leases string, l *lease, done chan struct{}) {
+	t := time.NewTicker(leaseTTL / 3)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case <-t.C:
+			alive, err := refreshScript.Run(context.Background(), r.c, []string{leases},
+				nowMillis(), leaseTTL.Milliseconds(), l.id).Int()
+
+			if err == nil && alive == 0 {
+				// the takeover announcement is missed
+				l.doKick()
+				return
+			}
+		}
+	}
+}
+
+func (r *Redis) kick(ids string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range strings.Split(ids, ",") {
+		if l, ok := r.local[id]; ok {
+			l.doKick()
+		}
+	}
+}
+
+func (r *Redis) watchKicks(ctx context.Context) {
+	ps := r.c.Subscribe(ctx, kickChannel)
+	defer ps.Close()
+
+	for {
+		msg, err := ps.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			time.Sleep(time.Second)
+			continue
+		}
+
+		r.kick(msg.Payload)
+	}
+}
@@ -0,0 +1,117 @@
+package listeners
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+var ErrBusy = errors.New("the access key has reached its online listeners limit")
+
+// Lease is a registered listener connection
+type Lease interface {
+	// Kicked is closed once the listener is taken over by a newer connection
+	Kicked() <-chan struct{}
+	Release()
+}
+
+// Registry tracks the online listeners of the access keys
+type Registry interface {
+	Acquire(ctx context.Context, key string, p Policy) (Lease, error)
+}
+
+func newLeaseID() string {
+	raw := make([]byte, 8)
+	_, _ = rand.Read(raw)
+
+	return hex.EncodeToString(raw)
+}
+
+type lease struct {
+	id        string
+	kicked    chan struct{}
+	kick      *sync.Once
+	release   *sync.Once
+	onRelease func()
+}
+
+func newLease() *lease {
+	return &lease{
+		id:      newLeaseID(),
+		kicked:  make(chan struct{}),
+		kick:    &sync.Once{},
+		release: &sync.Once{},
+	}
+}
+
+func (l *lease) Kicked() <-chan struct{} {
+	return l.kicked
+}
+
+func (l *lease) doKick() {
+	l.kick.Do(func() {
+		close(l.kicked)
+	})
+}
+
+func (l *lease) Release() {
+	l.release.Do(l.onRelease)
+}
+
+// Local tracks the listeners of a single replica
+type Local struct {
+	mu     *sync.Mutex
+	online map[string][]*lease
+}
+
+func NewLocal() *Local {
+	return &Local{mu: &sync.Mutex{}, online: map[string][]*lease{}}
+}
+
+func (r *Local) Acquire(_ context.Context, key string, p Policy) (Lease, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.online[key]
+
+	if p.Mode == ModeTakeover {
+		for _, l := range current {
+			l.doKick()
+		}
+
+		current = nil
+	} else if len(current) >= p.Max {
+		return nil, ErrBusy
+	}
+
+	l := newLease()
+	l.onRelease = func() {
+		r.remove(key, l)
+	}
+
+	r.online[key] = append(current, l)
+
+	return l, nil
+}
+
+func (r *Local) remove(key string, l *lease) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.online[key]
+
+	for i, other := range current {
+		if other == l {
+			current = append(current[:i], current[i+1:]...)
+			break
+		}
+	}
+
+	if len(current) == 0 {
+		delete(r.online, key)
+	} else {
+		r.online[key] = current
+	}
+}
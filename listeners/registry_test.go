@@ -0,0 +1,73 @@
+package listeners
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLocalPolicies(t *testing.T) {
+	r := NewLocal()
+	ctx := context.Background()
+
+	first, err := r.Acquire(ctx, "k", Exclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Acquire(ctx, "k", Exclusive); err != ErrBusy {
+		t.Errorf("second exclusive listener: expected ErrBusy, got %v", err)
+	}
+
+	first.Release()
+	first.Release()
+
+	shared := Policy{Mode: ModeShared, Max: 2}
+	for i := 0; i < 2; i++ {
+		if _, err := r.Acquire(ctx, "s", shared); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := r.Acquire(ctx, "s", shared); err != ErrBusy {
+		t.Errorf("third shared listener: expected ErrBusy, got %v", err)
+	}
+
+	takeover := Policy{Mode: ModeTakeover, Max: 1}
+
+	old, _ := r.Acquire(ctx, "t", takeover)
+	newer, _ := r.Acquire(ctx, "t", takeover)
+
+	select {
+	case <-old.Kicked():
+	default:
+		t.Error("old listener is not kicked")
+	}
+
+	old.Release()
+
+	select {
+	case <-newer.Kicked():
+		t.Error("new listener is kicked")
+	default:
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for raw, expected := range map[string]Policy{
+		"":          {},
+		"exclusive": Exclusive,
+		"Takeover":  {Mode: ModeTakeover, Max: 1},
+		"shared:3":  {Mode: ModeShared, Max: 3},
+	} {
+		p, err := ParsePolicy(raw)
+		if err != nil || p != expected {
+			t.Errorf("%q: got %+v, %v", raw, p, err)
+		}
+	}
+
+	for _, raw := range []string{"shared", "shared:0", "many"} {
+		if _, err := ParsePolicy(raw); err == nil {
+			t.Errorf("%q: expected an error", raw)
+		}
+	}
+}
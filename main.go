@@ -2,13 +2,29 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"limq/api"
+	"limq/audit"
 	"limq/authenticator"
+	"limq/broker"
+	"limq/config"
+	"limq/idempotency"
+	"limq/listeners"
+	"limq/quota"
+	"limq/ratelimit"
+	"limq/replies"
+	"limq/storage"
+	"limq/tlsconfig"
+	"limq/tracing"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
@@ -16,61 +32,373 @@ import (
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
+
+	level := zap.NewAtomicLevel()
+
 	{
-		var config zap.Config
+		var zc zap.Config
 
-		if len(os.Getenv("DEBUG")) > 0 {
-			config = zap.NewDevelopmentConfig()
-			config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		if cfg.Debug {
+			zc = zap.NewDevelopmentConfig()
+			zc.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 		} else {
-			config = zap.NewProductionConfig()
+			zc = zap.NewProductionConfig()
 		}
 
-		logger, _ := config.Build()
+		// validated by config.Load
+		l, _ := cfg.Level()
+		level.SetLevel(l)
+		zc.Level = level
+
+		logger, _ := zc.Build()
 		zap.ReplaceGlobals(logger)
 		defer logger.Sync()
 	}
 
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     envOrDefault("REDIS", "localhost:6379"),
-		Password: envOrDefault("REDIS_PASSWORD", ""),
-		DB:       envIntOrDefault("REDIS_DB", 3),
-	})
+	storage.DBTimeout = cfg.DBTimeout
+	quota.Set(cfg.Quotas)
+
+	tracer, err := acquireTracer(cfg.Tracing)
+	if err != nil {
+		zap.L().Fatal("unable to set up tracing", zap.Error(err))
+	}
+
+	tracing.SetDefault(tracer)
+	defer tracer.Shutdown()
+
+	deps, err := acquireBackends(cfg)
+	if err != nil {
+		zap.L().Fatal("unable to set up the backends", zap.Error(err))
+	}
 
-	pool, err := acquirePg()
+	auditLog, err := acquireAuditLog(cfg.Audit, deps.pool)
 	if err != nil {
-		zap.L().Fatal("unable to set up postgresql", zap.Error(err))
+		zap.L().Fatal("unable to set up the audit log", zap.Error(err))
 	}
 
-	authManager := authenticator.NewA(rdb)
-	stubManager := api.NewStub(pool, authManager)
+	authManager := authenticator.NewSigned(deps.auth, []byte(cfg.Auth.TokenSecret))
+	listenerPolicy, err := listeners.ParsePolicy(cfg.ListenerPolicy)
+	if err != nil {
+		zap.L().Fatal("invalid listener_policy", zap.Error(err))
+	}
+
+	clientCerts, err := acquireCertMap(cfg.TLS)
+	if err != nil {
+		zap.L().Fatal("unable to load the client certificates map", zap.Error(err))
+	}
+
+	rl := &reloader{args: os.Args[1:], current: cfg, level: level}
+
+	stubManager := api.NewStub(authManager, api.Options{
+		CORS:       corsPolicy(cfg.CORS),
+		AdminToken: cfg.AdminToken,
+		KeyStore:   deps.keys,
+		Storage:    deps.storage,
+		Audit:      auditLog,
+		Limiter:    deps.limiter,
+		Listeners:  deps.listeners,
+		Replies:    deps.replies,
+
+		Idempotency:       deps.dedup,
+		IdempotencyWindow: cfg.IdempotencyWindow,
+
+		ListenerPolicy: listenerPolicy,
+		MaxForwardHops: cfg.MaxForwardHops,
+		Readiness:      deps.readiness,
+		Limits:         cfg.RateLimits,
+		ClientCerts:    clientCerts,
+		Reload:         rl.Reload,
+	})
+
+	rl.stub = stubManager
 
 	server := &fasthttp.Server{}
 	server.Handler = stubManager.Handler()
 
+	if len(cfg.Metrics.Address) > 0 {
+		go serveMetrics(cfg.Metrics.Address, stubManager.MetricsHandler(cfg.Metrics.PerChannel))
+	}
+
+	go rl.watch()
+
 	signalNotifier := make(chan os.Signal, 1)
-	signal.Notify(signalNotifier, os.Interrupt, syscall.SIGTERM, syscall.SIGSTOP)
+	signal.Notify(signalNotifier, os.Interrupt, syscall.SIGTERM)
+
+	terminated := make(chan struct{})
 
 	go func() {
+		defer close(terminated)
+
 		<-signalNotifier
-		server.Shutdown()
-		zap.L()
+		drain(server, stubManager, cfg.ShutdownTimeout)
 	}()
 
-	address := envOrDefault("ADDRESS", ":8081")
+	ln, err := listen(cfg.Address, cfg.TLS)
+	if err != nil {
+		zap.L().Fatal("unable to listen", zap.Error(err))
+	}
 
-	zap.L().Info("starting the server", zap.String("address", address))
+	zap.L().Info("starting the server", zap.String("address", cfg.Address), zap.Bool("tls", cfg.TLS.Enabled()),
+		zap.Bool("standalone", cfg.Standalone))
 
-	if err := server.ListenAndServe(address); err != nil {
+	if err := server.Serve(ln); err != nil {
 		zap.L().Error("error on startup: " + err.Error())
+	} else {
+		<-terminated
 	}
 
 	zap.L().Info("server is terminated")
 }
 
-func acquirePg() (*pgxpool.Pool, error) {
+// drain lets the listeners go and the undelivered messages reach the storage, then stops the server.
+// Everything is done within the timeout
+func drain(server *fasthttp.Server, stub *api.Stub, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	zap.L().Info("draining", zap.Duration("timeout", timeout))
+
+	stub.Drain(ctx)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- server.Shutdown()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			zap.L().Warn("unable to shut down the server", zap.Error(err))
+		}
+
+	case <-ctx.Done():
+		zap.L().Warn("shutdown deadline is exceeded, open connections are dropped")
+	}
+}
+
+// listen opens the API listener, a TLS one if the certificate is configured
+func listen(address string, c config.TLS) (net.Listener, error) {
+	ln, err := net.Listen("tcp4", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.Enabled() {
+		return ln, nil
+	}
+
+	r, err := tlsconfig.New(tlsconfig.Options{
+		CertFile:     c.CertFile,
+		KeyFile:      c.KeyFile,
+		ClientCAFile: c.ClientCAFile,
+		ClientAuth:   c.ClientAuth,
+	})
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return tls.NewListener(ln, r.Config()), nil
+}
+
+// acquireCertMap loads the mapping of the mTLS clients onto channels, mTLS clients are not served without it
+func acquireCertMap(c config.TLS) (*authenticator.CertMap, error) {
+	if len(c.ClientMap) == 0 {
+		return nil, nil
+	}
+
+	return authenticator.LoadCertMap(c.ClientMap)
+}
+
+// serveMetrics serves the metrics on a separate address, which is not meant to be public
+func serveMetrics(address string, handler fasthttp.RequestHandler) {
+	zap.L().Info("starting the metrics server", zap.String("address", address))
+
+	if err := fasthttp.ListenAndServe(address, handler); err != nil {
+		zap.L().Error("metrics server failed", zap.Error(err))
+	}
+}
+
+// acquireTracer sets up the span exporter, tracing is disabled by default
+func acquireTracer(c config.Tracing) (*tracing.Tracer, error) {
+	switch exporter := c.Exporter; exporter {
+	case "", "none":
+		return tracing.NewTracer(nil), nil
+
+	case "stdout":
+		return tracing.NewTracer(tracing.NewWriter(os.Stdout)), nil
+
+	case "otlp":
+		return tracing.NewTracer(tracing.NewOTLP(c.OTLPEndpoint)), nil
+
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporter)
+	}
+}
+
+// backends are the state shared by the replicas, or its in-process replacement in the standalone mode
+type backends struct {
+	auth      authenticator.Authenticator
+	keys      authenticator.KeyStore
+	storage   broker.Storage
+	limiter   ratelimit.Limiter
+	listeners listeners.Registry
+	dedup     idempotency.Store
+	replies   replies.Router
+	readiness []api.ReadinessCheck
+
+	// pool is nil in the standalone mode
+	pool *pgxpool.Pool
+}
+
+// acquireBackends connects to Redis and Postgres. The standalone mode needs neither of them:
+// the descriptors come from the auth backend files and everything else is kept in memory
+func acquireBackends(cfg config.Config) (backends, error) {
+	if cfg.Standalone {
+		// the backend is validated by config.Load
+		backend, _, err := acquireAuthenticator(cfg.Auth, nil, nil)
+		if err != nil {
+			return backends{}, fmt.Errorf("authenticator: %w", err)
+		}
+
+		return backends{
+			auth:      backend,
+			storage:   storage.NewMemory(),
+			limiter:   ratelimit.NewLocal(),
+			listeners: listeners.NewLocal(),
+			dedup:     idempotency.NewLocal(),
+			replies:   replies.NewLocal(),
+		}, nil
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Address,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+
+	pool, err := acquirePg(cfg.DatabaseURL)
+	if err != nil {
+		return backends{}, fmt.Errorf("postgresql: %w", err)
+	}
+
+	if err := migrate(pool); err != nil {
+		return backends{}, fmt.Errorf("unable to migrate the messages table: %w", err)
+	}
+
+	backend, keyStore, err := acquireAuthenticator(cfg.Auth, rdb, pool)
+	if err != nil {
+		return backends{}, fmt.Errorf("authenticator: %w", err)
+	}
+
+	return backends{
+		auth:      backend,
+		keys:      keyStore,
+		storage:   storage.NewKeeper(pool),
+		limiter:   ratelimit.NewRedis(rdb),
+		listeners: listeners.NewRedis(context.Background(), rdb),
+		dedup:     idempotency.NewRedis(rdb),
+		replies:   replies.NewRedis(context.Background(), rdb),
+		readiness: readinessChecks(rdb, pool, backend),
+		pool:      pool,
+	}, nil
+}
+
+// readinessChecks probes Redis (through the authenticator if it's Redis-backed), Postgres and its schema
+func readinessChecks(rdb *redis.Client, pool *pgxpool.Pool, backend authenticator.Authenticator) []api.ReadinessCheck {
+	pingRedis := func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}
+
+	if a, ok := backend.(*authenticator.A); ok {
+		pingRedis = a.Ping
+	}
+
+	return []api.ReadinessCheck{
+		{Name: "redis", Probe: pingRedis},
+		{Name: "postgres", Probe: pool.Ping},
+		{Name: "schema", Probe: func(ctx context.Context) error {
+			return storage.CheckSchema(ctx, pool)
+		}},
+	}
+}
+
+func acquirePg(url string) (*pgxpool.Pool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return pgxpool.Connect(ctx, url)
+}
+
+func migrate(pool *pgxpool.Pool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return storage.Migrate(ctx, pool)
+}
+
+// acquireAuthenticator sets up the configured authenticator backend.
+// The returned KeyStore is nil unless the backend is manageable through the admin API
+func acquireAuthenticator(c config.Auth, rdb *redis.Client, pool *pgxpool.Pool) (authenticator.Authenticator, authenticator.KeyStore, error) {
+	switch backend := c.Backend; backend {
+	case "redis":
+		a := authenticator.NewA(rdb)
+		a.EnableCache(context.Background(), c.CacheTTL)
+
+		return a, a, nil
+
+	case "file":
+		a, err := authenticator.LoadStatic(c.File)
+		return a, nil, err
+
+	case "postgres":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		a, err := authenticator.NewPostgres(ctx, pool)
+		return a, nil, err
+
+	case "jwt":
+		a, err := authenticator.LoadJWT(c.JWKSFile, c.JWTIssuer, c.JWTAudience)
+		return a, nil, err
+
+	default:
+		return nil, nil, fmt.Errorf("unknown auth backend %q", backend)
+	}
+}
+
+// acquireAuditLog writes the audit events to the audit log path (the main log by default)
+// and to the audit_log table if enabled
+func acquireAuditLog(c config.Audit, pool *pgxpool.Pool) (*audit.Log, error) {
+	logger := zap.L().Named("audit")
+
+	if len(c.LogPath) > 0 {
+		zc := zap.NewProductionConfig()
+		zc.OutputPaths = []string{c.LogPath}
+		zc.Sampling = nil
+
+		l, err := zc.Build()
+		if err != nil {
+			return nil, err
+		}
+
+		logger = l.Named("audit")
+	}
+
+	if !c.DB {
+		pool = nil
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	return pgxpool.Connect(ctx, os.Getenv("DATABASE_URL"))
+	return audit.New(ctx, logger, pool, c.Publish)
 }
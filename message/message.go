@@ -5,4 +5,31 @@ type Message struct {
 	Scope     Scope
 	ChannelID string
 	Payload   []byte
+
+	// Headers are the user-defined metadata, names are lowercase
+	Headers map[string]string
+
+	// Path lists the channels a forwarded message went through, the origin first.
+	// It is empty for a message published directly to ChannelID
+	Path []string
+
+	// TraceParent is the W3C trace context of the message, empty if it's not traced
+	TraceParent string
+
+	// CorrelationID identifies the request awaiting a reply, empty if the message is not a request
+	CorrelationID string
+}
+
+// Origin returns the channel the message was published to
+func (m *Message) Origin() string {
+	if len(m.Path) == 0 {
+		return m.ChannelID
+	}
+
+	return m.Path[0]
+}
+
+// Hops returns the number of times the message was forwarded
+func (m *Message) Hops() int {
+	return len(m.Path)
 }
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the default upper bounds of the latency histograms, in seconds
+var LatencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+type histogramSample struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec counts observations in buckets, partitioned by labels
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      *sync.Mutex
+	series  map[string]*histogramSample
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		mu:      &sync.Mutex{},
+		series:  map[string]*histogramSample{},
+	}
+
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labels ...string) {
+	h.checkLabels(labels)
+
+	key := labelKey(labels)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSample{labels: append([]string(nil), labels...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+
+	s.count++
+	s.sum += value
+}
+
+// Since observes the time elapsed since start in seconds
+func (h *HistogramVec) Since(start time.Time, labels ...string) {
+	h.Observe(time.Since(start).Seconds(), labels...)
+}
+
+func (h *HistogramVec) write(b *bytes.Buffer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(b)
+
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+
+		for i, bound := range h.buckets {
+			h.desc.series(b, "_bucket", s.labels, "le", formatFloat(bound))
+			b.WriteString(" " + formatUint(s.counts[i]) + "\n")
+		}
+
+		h.desc.series(b, "_bucket", s.labels, "le", "+Inf")
+		b.WriteString(" " + formatUint(s.count) + "\n")
+
+		h.desc.series(b, "_sum", s.labels)
+		b.WriteString(" " + formatFloat(s.sum) + "\n")
+
+		h.desc.series(b, "_count", s.labels)
+		b.WriteString(" " + formatUint(s.count) + "\n")
+	}
+}
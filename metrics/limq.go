@@ -0,0 +1,28 @@
+package metrics
+
+// the limq metrics, label values are bounded unless noted otherwise
+var (
+	Publishes = Default.NewCounter("limq_publish_total", "Publish requests by API status code.", "code")
+	Listens   = Default.NewCounter("limq_listen_total", "Listen requests by mode (poll, ws) and API status code.", "mode", "code")
+
+	PayloadBytes = Default.NewCounter("limq_payload_bytes_total", "Message payload bytes by direction (published, delivered).", "direction")
+
+	WSConnections = Default.NewGauge("limq_ws_connections", "Open WebSocket subscriptions.")
+
+	OnlineListeners  = Default.NewGauge("limq_online_listeners", "Listeners waiting for messages on this replica.")
+	BufferedMessages = Default.NewGauge("limq_buffered_messages", "Messages buffered in Postgres.")
+
+	// ChannelOnlineListeners and ChannelBufferedMessages are labelled by channel tag, collected only if enabled
+	ChannelOnlineListeners  = Default.NewGauge("limq_channel_online_listeners", "Listeners waiting for messages on this replica by channel.", "channel")
+	ChannelBufferedMessages = Default.NewGauge("limq_channel_buffered_messages", "Messages buffered in Postgres by channel.", "channel")
+
+	KeeperPut    = Default.NewHistogram("limq_keeper_put_seconds", "Latency of buffering a message in Postgres.", LatencyBuckets)
+	ReadBuffered = Default.NewHistogram("limq_read_buffered_seconds", "Latency of reading a buffered message from Postgres.", LatencyBuckets)
+
+	PoolConnections = Default.NewGauge("limq_pg_pool_connections", "Postgres pool connections by state (acquired, idle, constructing, total, max).", "state")
+	PoolAcquires    = Default.NewGauge("limq_pg_pool_acquires", "Postgres pool acquisitions since start by kind (all, empty, canceled).", "kind")
+
+	AuthLatency = Default.NewHistogram("limq_auth_seconds", "Latency of resolving an access key by the backend, cache hits excluded.", LatencyBuckets, "backend")
+
+	Forwards = Default.NewCounter("limq_forward_total", "Forwarding results (delivered, retried, failed, dropped).", "result")
+)
@@ -0,0 +1,139 @@
+// Package metrics is a minimal collector of metrics exposed in the Prometheus text format
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type collector interface {
+	write(b *bytes.Buffer)
+}
+
+// Registry renders the registered metrics in the order of registration
+type Registry struct {
+	mu         *sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{mu: &sync.Mutex{}}
+}
+
+// Default is the registry of the limq metrics
+var Default = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := r.collectors
+	r.mu.Unlock()
+
+	b := &bytes.Buffer{}
+
+	for _, c := range collectors {
+		c.write(b)
+	}
+
+	return b.WriteTo(w)
+}
+
+// desc is the metric family description shared by the vectors
+type desc struct {
+	name, help, typ string
+	labels          []string
+}
+
+func (d *desc) header(b *bytes.Buffer) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.typ)
+}
+
+// series formats the name{labels} part of a sample, extra is an additional label pair
+func (d *desc) series(b *bytes.Buffer, suffix string, values []string, extra ...string) {
+	b.WriteString(d.name)
+	b.WriteString(suffix)
+
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return
+	}
+
+	b.WriteByte('{')
+
+	for i, l := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		writeLabel(b, l, values[i])
+	}
+
+	if len(extra) == 2 {
+		if len(d.labels) > 0 {
+			b.WriteByte(',')
+		}
+
+		writeLabel(b, extra[0], extra[1])
+	}
+
+	b.WriteByte('}')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func writeLabel(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	b.WriteString(`="`)
+	labelEscaper.WriteString(b, value)
+	b.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+
+	case math.IsInf(v, -1):
+		return "-Inf"
+
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+// labelKey identifies the series of a vector, label values can't contain the separator
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func (d *desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s: %d label values for %d labels", d.name, len(values), len(d.labels)))
+	}
+}
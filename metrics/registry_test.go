@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.NewCounter("test_total", "A counter.", "code")
+	c.Inc("1")
+	c.Add(2, "0")
+
+	h := r.NewHistogram("test_seconds", "A histogram.", []float64{.1, 1})
+	h.Observe(.5)
+	h.Observe(2)
+
+	g := r.NewGauge("test_gauge", "A gauge.", "channel")
+	g.Set(3, `a"b`)
+
+	b := &bytes.Buffer{}
+	if _, err := r.WriteTo(b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP test_total A counter.
+# TYPE test_total counter
+test_total{code="0"} 2
+test_total{code="1"} 1
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 0
+test_seconds_bucket{le="1"} 1
+test_seconds_bucket{le="+Inf"} 2
+test_seconds_sum 2.5
+test_seconds_count 2
+# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge{channel="a\"b"} 3
+`
+
+	if b.String() != expected {
+		t.Errorf("unexpected exposition:\n%s", b.String())
+	}
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"bytes"
+	"sync"
+)
+
+type sample struct {
+	labels []string
+	value  float64
+}
+
+// valueVec is a set of float series partitioned by label values, a counter or a gauge
+type valueVec struct {
+	desc
+	mu     *sync.Mutex
+	series map[string]*sample
+}
+
+func newValueVec(r *Registry, typ, name, help string, labels []string) *valueVec {
+	v := &valueVec{
+		desc:   desc{name: name, help: help, typ: typ, labels: labels},
+		mu:     &sync.Mutex{},
+		series: map[string]*sample{},
+	}
+
+	r.register(v)
+	return v
+}
+
+func (v *valueVec) update(values []string, f func(s *sample)) {
+	v.checkLabels(values)
+
+	key := labelKey(values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.series[key]
+	if !ok {
+		s = &sample{labels: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+
+	f(s)
+}
+
+func (v *valueVec) write(b *bytes.Buffer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.header(b)
+
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+
+		v.desc.series(b, "", s.labels)
+		b.WriteByte(' ')
+		b.WriteString(formatFloat(s.value))
+		b.WriteByte('\n')
+	}
+}
+
+// CounterVec is a monotonic counter partitioned by labels
+type CounterVec struct {
+	v *valueVec
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newValueVec(r, "counter", name, help, labels)}
+}
+
+func (c *CounterVec) Add(delta float64, labels ...string) {
+	if delta < 0 {
+		return
+	}
+
+	c.v.update(labels, func(s *sample) { s.value += delta })
+}
+
+func (c *CounterVec) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// GaugeVec is a value going up and down partitioned by labels
+type GaugeVec struct {
+	v *valueVec
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{newValueVec(r, "gauge", name, help, labels)}
+}
+
+func (g *GaugeVec) Set(value float64, labels ...string) {
+	g.v.update(labels, func(s *sample) { s.value = value })
+}
+
+func (g *GaugeVec) Add(delta float64, labels ...string) {
+	g.v.update(labels, func(s *sample) { s.value += delta })
+}
+
+// Reset drops every series, for the gauges refreshed as a whole on collection
+func (g *GaugeVec) Reset() {
+	g.v.mu.Lock()
+	defer g.v.mu.Unlock()
+
+	g.v.series = map[string]*sample{}
+}
@@ -0,0 +1,26 @@
+package quota
+
+// Limits are the per access key rate limits.
+// Zero means "not set", a negative value disables the limit
+type Limits struct {
+	MessagesPerSecond float64 `json:"messages_per_second,omitempty" yaml:"messages_per_second"`
+	BytesPerSecond    float64 `json:"bytes_per_second,omitempty" yaml:"bytes_per_second"`
+	Concurrent        int     `json:"concurrent,omitempty" yaml:"concurrent"`
+}
+
+// Resolve fills the limits which are not set with the defaults
+func (l Limits) Resolve(defaults Limits) Limits {
+	if l.MessagesPerSecond == 0 {
+		l.MessagesPerSecond = defaults.MessagesPerSecond
+	}
+
+	if l.BytesPerSecond == 0 {
+		l.BytesPerSecond = defaults.BytesPerSecond
+	}
+
+	if l.Concurrent == 0 {
+		l.Concurrent = defaults.Concurrent
+	}
+
+	return l
+}
@@ -1,10 +1,69 @@
 package quota
 
-const (
-	kb = 1 << 10
+import (
+	"sync/atomic"
+	"time"
+)
 
-	MaxMessageSize      = 256 * kb
-	MaxBufferedMessages = 256
+const kb = 1 << 10
 
-	MaxSizePerQueue = MaxMessageSize * MaxBufferedMessages
-)
+// Quotas bound the messages and the listeners, they may change at runtime
+type Quotas struct {
+	MaxMessageSize      int `yaml:"max_message_size"`
+	MaxBufferedMessages int `yaml:"max_buffered_messages"`
+
+	// MaxMessageHeaders and MaxHeadersSize bound the user headers of a message
+	MaxMessageHeaders int `yaml:"max_message_headers"`
+	MaxHeadersSize    int `yaml:"max_headers_size"`
+
+	// ListenTimeout is the long poll timeout used when the client sends no X-Timeout
+	ListenTimeout time.Duration `yaml:"listen_timeout"`
+}
+
+var Defaults = Quotas{
+	MaxMessageSize:      256 * kb,
+	MaxBufferedMessages: 256,
+	MaxMessageHeaders:   16,
+	MaxHeadersSize:      4 * kb,
+	ListenTimeout:       25 * time.Second,
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(Defaults)
+}
+
+// Current returns the quotas in effect
+func Current() Quotas {
+	return current.Load().(Quotas)
+}
+
+// Set replaces the quotas in effect, the ones not set fall back to the defaults
+func Set(q Quotas) {
+	if q.MaxMessageSize <= 0 {
+		q.MaxMessageSize = Defaults.MaxMessageSize
+	}
+
+	if q.MaxBufferedMessages <= 0 {
+		q.MaxBufferedMessages = Defaults.MaxBufferedMessages
+	}
+
+	if q.MaxMessageHeaders <= 0 {
+		q.MaxMessageHeaders = Defaults.MaxMessageHeaders
+	}
+
+	if q.MaxHeadersSize <= 0 {
+		q.MaxHeadersSize = Defaults.MaxHeadersSize
+	}
+
+	if q.ListenTimeout <= 0 {
+		q.ListenTimeout = Defaults.ListenTimeout
+	}
+
+	current.Store(q)
+}
+
+func (q Quotas) MaxSizePerQueue() int {
+	return q.MaxMessageSize * q.MaxBufferedMessages
+}
@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"limq/quota"
+	"time"
+)
+
+var ErrLimited = errors.New("rate limit exceeded")
+
+// Limiter enforces quota.Limits shared by all the replicas
+type Limiter interface {
+	// Allow takes one message of size bytes out of the key's token buckets.
+	// On ErrLimited it reports when the request may be retried
+	Allow(ctx context.Context, key string, l quota.Limits, size int) (retryAfter time.Duration, err error)
+
+	// Acquire takes one of the key's concurrent request slots.
+	// The returned release func must be called once the request is finished
+	Acquire(ctx context.Context, key string, l quota.Limits) (release func(), err error)
+}
+
+func enabled(limit float64) bool {
+	return limit > 0
+}
@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"limq/quota"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the period of dropping the expired buckets
+const sweepEvery = time.Minute
+
+type bucket struct {
+	tokens float64
+	ts     time.Time
+
+	// expires is the moment the bucket is full again and may be forgotten
+	expires time.Time
+}
+
+// Local is the single replica Limiter, it keeps the same token buckets as Redis in the process' memory
+type Local struct {
+	mu      *sync.Mutex
+	buckets map[string]*bucket
+	slots   map[string]int
+	swept   time.Time
+}
+
+func NewLocal() *Local {
+	return &Local{
+		mu:      &sync.Mutex{},
+		buckets: map[string]*bucket{},
+		slots:   map[string]int{},
+		swept:   time.Now(),
+	}
+}
+
+func (l *Local) Allow(_ context.Context, key string, limits quota.Limits, size int) (time.Duration, error) {
+	if !enabled(limits.MessagesPerSecond) && !enabled(limits.BytesPerSecond) {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	checks := []struct {
+		key  string
+		rate float64
+		cost float64
+	}{
+		{"m_" + key, limits.MessagesPerSecond, 1},
+		{"b_" + key, limits.BytesPerSecond, float64(size)},
+	}
+
+	var (
+		delay  time.Duration
+		tokens [2]float64
+	)
+
+	// both of the buckets are checked before any tokens are taken
+	for i, c := range checks {
+		if !enabled(c.rate) || c.cost <= 0 {
+			continue
+		}
+
+		burst := math.Max(c.rate, c.cost)
+		tokens[i] = burst
+
+		if b, ok := l.buckets[c.key]; ok {
+			tokens[i] = math.Min(burst, b.tokens+now.Sub(b.ts).Seconds()*c.rate)
+		}
+
+		if tokens[i] < c.cost {
+			wait := time.Duration(math.Ceil((c.cost-tokens[i])*1000/c.rate)) * time.Millisecond
+			if wait > delay {
+				delay = wait
+			}
+		}
+	}
+
+	if delay > 0 {
+		return delay, ErrLimited
+	}
+
+	for i, c := range checks {
+		if !enabled(c.rate) || c.cost <= 0 {
+			continue
+		}
+
+		refill := time.Duration(math.Max(c.rate, c.cost) / c.rate * float64(time.Second))
+		l.buckets[c.key] = &bucket{tokens: tokens[i] - c.cost, ts: now, expires: now.Add(refill)}
+	}
+
+	return 0, nil
+}
+
+func (l *Local) Acquire(_ context.Context, key string, limits quota.Limits) (func(), error) {
+	if limits.Concurrent <= 0 {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.slots[key] >= limits.Concurrent {
+		return nil, ErrLimited
+	}
+
+	l.slots[key]++
+
+	once := &sync.Once{}
+
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			if l.slots[key]--; l.slots[key] <= 0 {
+				delete(l.slots, key)
+			}
+		})
+	}, nil
+}
+
+// sweep drops the buckets that are refilled by now
+func (l *Local) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepEvery {
+		return
+	}
+
+	l.swept = now
+
+	for key, b := range l.buckets {
+		if !now.Before(b.expires) {
+			delete(l.buckets, key)
+		}
+	}
+}
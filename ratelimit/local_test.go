@@ -0,0 +1,34 @@
+package ratelimit
+
+import (
+	"context"
+	"limq/quota"
+	"testing"
+)
+
+func TestLocalReleaseOnce(t *testing.T) {
+	l := NewLocal()
+	ctx := context.Background()
+	limits := quota.Limits{Concurrent: 2}
+
+	release, err := l.Acquire(ctx, "key", limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.Acquire(ctx, "key", limits); err != nil {
+		t.Fatal(err)
+	}
+
+	// a release called twice must free a single slot
+	release()
+	release()
+
+	if _, err := l.Acquire(ctx, "key", limits); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.Acquire(ctx, "key", limits); err != ErrLimited {
+		t.Errorf("expected the limit to hold, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"limq/quota"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	bucketsPrefix = `limq_rate_`
+	slotsPrefix   = `limq_slots_`
+
+	// slotTTL bounds the lifetime of the slots left by crashed replicas,
+	// slots of long-living requests are prolonged in background
+	slotTTL = 30 * time.Second
+)
+
+// allowScript checks the messages and bytes token buckets at once and takes the tokens
+// only if both have enough of them. Returns 0 or the retry-after delay in milliseconds
+var allowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local result = 0
+local state = {}
+
+for i = 1, #KEYS do
+	local rate = tonumber(ARGV[i * 2])
+	local cost = tonumber(ARGV[i * 2 + 1])
+
+	if rate > 0 and cost > 0 then
+		local burst = math.max(rate, cost)
+		local bucket = redis.call('HMGET', KEYS[i], 'tokens', 'ts')
+		local tokens = tonumber(bucket[1]) or burst
+		local ts = tonumber(bucket[2]) or now
+
+		tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+
+		if tokens < cost then
+			result = math.max(result, math.ceil((cost - tokens) * 1000 / rate))
+		end
+
+		state[i] = {tokens - cost, math.ceil(burst / rate * 1000)}
+	end
+end
+
+if result > 0 then
+	return result
+end
+
+for i, s in pairs(state) do
+	redis.call('HSET', KEYS[i], 'tokens', s[1], 'ts', now)
+	redis.call('PEXPIRE', KEYS[i], s[2] + 1000)
+end
+
+return 0
+`)
+
+// acquireScript takes a slot if less than ARGV[4] of unexpired ones are taken
+var acquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[4]) then
+	return 0
+end
+
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[2]), ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// Redis keeps the limiter state in Redis so that the limits hold across replicas.
+// Redis failures don't block the requests
+type Redis struct {
+	c *redis.Client
+}
+
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{c: client}
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func (r *Redis) Allow(ctx context.Context, key string, l quota.Limits, size int) (time.Duration, error) {
+	if !enabled(l.MessagesPerSecond) && !enabled(l.BytesPerSecond) {
+		return 0, nil
+	}
+
+	keys := []string{bucketsPrefix + "m_" + key, bucketsPrefix + "b_" + key}
+	args := []any{
+		nowMillis(),
+		strconv.FormatFloat(l.MessagesPerSecond, 'f', -1, 64), 1,
+		strconv.FormatFloat(l.BytesPerSecond, 'f', -1, 64), size,
+	}
+
+	delay, err := allowScript.Run(ctx, r.c, keys, args...).Int64()
+	if err != nil {
+		zap.L().Warn("rate limiter is unavailable", zap.Error(err))
+		return 0, nil
+	}
+
+	if delay > 0 {
+		return time.Duration(delay) * time.Millisecond, ErrLimited
+	}
+
+	return 0, nil
+}
+
+func (r *Redis) Acquire(ctx context.Context, key string, l quota.Limits) (func(), error) {
+	if l.Concurrent <= 0 {
+		return func() {}, nil
+	}
+
+	raw := make([]byte, 8)
+	_, _ = rand.Read(raw)
+	slot := hex.EncodeToString(raw)
+	slots := slotsPrefix + key
+
+	ok, err := acquireScript.Run(ctx, r.c, []string{slots}, nowMillis(), slotTTL.Milliseconds(), slot, l.Concurrent).Bool()
+	if err != nil {
+		zap.L().Warn("rate limiter is unavailable", zap.Error(err))
+		return func() {}, nil
+	}
+
+	if !ok {
+		return nil, ErrLimited
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		t := time.NewTicker(slotTTL / 3)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+
+			case <-t.C:
+				r.c.ZAdd(context.Background(), slots, &redis.Z{Score: float64(nowMillis() + slotTTL.Milliseconds()), Member: slot})
+				r.c.PExpire(context.Background(), slots, slotTTL)
+			}
+		}
+	}()
+
+	once := &sync.Once{}
+
+	return func() {
+		once.Do(func() {
+			close(done)
+			r.c.ZRem(context.Background(), slots, slot)
+		})
+	}, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"go.uber.org/zap"
+	"limq/api"
+	"limq/config"
+	"limq/quota"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// reloader applies the settings which can change at runtime: quotas, CORS, rate limits and log level
+type reloader struct {
+	mu      sync.Mutex
+	args    []string
+	current config.Config
+	level   zap.AtomicLevel
+	stub    *api.Stub
+}
+
+func corsPolicy(c config.CORS) api.CorsPolicy {
+	return api.CorsPolicy{
+		AllowedOrigins:   api.ParseOrigins(strings.Join(c.Origins, ",")),
+		AllowCredentials: c.Credentials,
+	}
+}
+
+// Reload re-reads the env, the config file and the flags.
+// Nothing is applied if the new configuration is invalid
+func (r *reloader) Reload() (config.Report, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := config.Load(r.args)
+	if err != nil {
+		return config.Report{}, err
+	}
+
+	current, report := r.current.Reload(next)
+	r.current = current
+
+	// validated by config.Load
+	l, _ := current.Level()
+	r.level.SetLevel(l)
+
+	quota.Set(current.Quotas)
+	r.stub.Reconfigure(corsPolicy(current.CORS), current.RateLimits)
+
+	return report, nil
+}
+
+// watch reloads the configuration on SIGHUP
+func (r *reloader) watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		report, err := r.Reload()
+		if err != nil {
+			zap.L().Error("unable to reload the configuration, the current one is kept", zap.Error(err))
+			continue
+		}
+
+		zap.L().Info("configuration is reloaded",
+			zap.Strings("applied", report.Applied), zap.Strings("restart_required", report.RestartRequired))
+
+		if len(report.RestartRequired) > 0 {
+			zap.L().Warn("some changed settings take effect after a restart", zap.Strings("settings", report.RestartRequired))
+		}
+	}
+}
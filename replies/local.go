@@ -0,0 +1,77 @@
+package replies
+
+import (
+	"context"
+	"limq/message"
+	"sync"
+	"time"
+)
+
+type request struct {
+	reply  chan *message.Message
+	onDone func()
+	once   *sync.Once
+}
+
+func (r *request) Reply() <-chan *message.Message {
+	return r.reply
+}
+
+func (r *request) Done() {
+	r.once.Do(r.onDone)
+}
+
+// Local is the single replica Router
+type Local struct {
+	mu      *sync.Mutex
+	pending map[string]*request
+}
+
+func NewLocal() *Local {
+	return &Local{mu: &sync.Mutex{}, pending: map[string]*request{}}
+}
+
+func (l *Local) Await(_ context.Context, id string, _ time.Duration) (Request, error) {
+	return l.register(id), nil
+}
+
+func (l *Local) Reply(_ context.Context, id string, m *message.Message) error {
+	if !l.deliver(id, m) {
+		return ErrNoRequest
+	}
+
+	return nil
+}
+
+func (l *Local) register(id string) *request {
+	r := &request{reply: make(chan *message.Message, 1), once: &sync.Once{}}
+
+	r.onDone = func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.pending[id] == r {
+			delete(l.pending, id)
+		}
+	}
+
+	l.mu.Lock()
+	l.pending[id] = r
+	l.mu.Unlock()
+
+	return r
+}
+
+// deliver hands the reply over to the request awaiting on this replica, only the first reply is delivered
+func (l *Local) deliver(id string, m *message.Message) bool {
+	l.mu.Lock()
+	r, ok := l.pending[id]
+	delete(l.pending, id)
+	l.mu.Unlock()
+
+	if ok {
+		r.reply <- m
+	}
+
+	return ok
+}
@@ -0,0 +1,36 @@
+package replies
+
+import (
+	"context"
+	"limq/message"
+	"testing"
+	"time"
+)
+
+func TestLocal(t *testing.T) {
+	r := NewLocal()
+	ctx := context.Background()
+	id := NewCorrelationID()
+
+	req, _ := r.Await(ctx, id, time.Minute)
+	defer req.Done()
+
+	if err := r.Reply(ctx, id, &message.Message{Payload: []byte("first")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Reply(ctx, id, &message.Message{Payload: []byte("second")}); err != ErrNoRequest {
+		t.Error("only the first reply must be delivered", err)
+	}
+
+	if m := <-req.Reply(); string(m.Payload) != "first" {
+		t.Error("unexpected reply", string(m.Payload))
+	}
+
+	done, _ := r.Await(ctx, "done", time.Minute)
+	done.Done()
+
+	if err := r.Reply(ctx, "done", &message.Message{}); err != ErrNoRequest {
+		t.Error("finished request must not get a reply", err)
+	}
+}
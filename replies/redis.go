@@ -0,0 +1,111 @@
+package replies
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"limq/message"
+	"time"
+)
+
+const (
+	pendingPrefix  = `limq_reply_`
+	repliesChannel = `limq_replies`
+)
+
+// replyScript announces the reply if the request is still pending, so that only the first reply is delivered
+var replyScript = redis.NewScript(`
+if redis.call('DEL', KEYS[1]) == 0 then
+	return 0
+end
+
+redis.call('PUBLISH', ARGV[1], ARGV[2])
+return 1
+`)
+
+// announcement is a reply announced to the replicas over pub/sub
+type announcement struct {
+	ID          string            `json:"id"`
+	Type        message.Type      `json:"type"`
+	Payload     []byte            `json:"payload"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	TraceParent string            `json:"traceparent,omitempty"`
+}
+
+// Redis registers the pending requests of all the replicas in Redis.
+// A reply is announced over pub/sub to the replica holding the request
+type Redis struct {
+	c     *redis.Client
+	local *Local
+}
+
+// NewRedis creates the router and starts listening to the replies until ctx is done
+func NewRedis(ctx context.Context, client *redis.Client) *Redis {
+	r := &Redis{c: client, local: NewLocal()}
+
+	go r.watchReplies(ctx)
+
+	return r
+}
+
+func (r *Redis) Await(ctx context.Context, id string, timeout time.Duration) (Request, error) {
+	req := r.local.register(id)
+
+	if err := r.c.Set(ctx, pendingPrefix+id, 1, timeout).Err(); err != nil {
+		req.Done()
+		return nil, err
+	}
+
+	localDone := req.onDone
+	req.onDone = func() {
+		localDone()
+		r.c.Del(context.Background(), pendingPrefix+id)
+	}
+
+	return req, nil
+}
+
+func (r *Redis) Reply(ctx context.Context, id string, m *message.Message) error {
+	raw, err := json.Marshal(announcement{ID: id, Type: m.Type, Payload: m.Payload, Headers: m.Headers, TraceParent: m.TraceParent})
+	if err != nil {
+		return err
+	}
+
+	delivered, err := replyScript.Run(ctx, r.c, []string{pendingPrefix + id}, repliesChannel, raw).Int()
+	if err != nil {
+		return err
+	}
+
+	if delivered == 0 {
+		return ErrNoRequest
+	}
+
+	return nil
+}
+
+func (r *Redis) watchReplies(ctx context.Context) {
+	ps := r.c.Subscribe(ctx, repliesChannel)
+	defer ps.Close()
+
+	for {
+		msg, err := ps.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			time.Sleep(time.Second)
+			continue
+		}
+
+		a := announcement{}
+		if err := json.Unmarshal([]byte(msg.Payload), &a); err != nil {
+			zap.L().Warn("malformed reply announcement", zap.Error(err))
+			continue
+		}
+
+		// the other replicas' requests are not found here
+		r.local.deliver(a.ID, &message.Message{Type: a.Type, Payload: a.Payload, Headers: a.Headers, TraceParent: a.TraceParent})
+	}
+}
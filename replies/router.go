@@ -0,0 +1,40 @@
+package replies
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"limq/message"
+	"time"
+)
+
+var ErrNoRequest = errors.New("no request awaits the reply")
+
+// Request awaits its reply
+type Request interface {
+	// Reply delivers the reply once it comes
+	Reply() <-chan *message.Message
+
+	// Done stops awaiting the reply, it must be called once the request is finished
+	Done()
+}
+
+// Router hands the replies over to the requests awaiting them on any replica
+type Router interface {
+	// Await registers the request with the correlation id for up to timeout
+	Await(ctx context.Context, id string, timeout time.Duration) (Request, error)
+
+	// Reply hands the reply over to the request with the correlation id.
+	// ErrNoRequest is returned if the request is not awaited (any more), e.g. replied already or timed out
+	Reply(ctx context.Context, id string, m *message.Message) error
+}
+
+// NewCorrelationID generates a random correlation ID.
+// It's the only credential of a reply, so it must not be guessable
+func NewCorrelationID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
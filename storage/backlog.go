@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"limq/message"
+)
+
+// Backlog returns the buffered messages of the channel, the oldest first, without consuming them
+func (k *Keeper) Backlog(ctx context.Context, tag string) ([]*message.Message, error) {
+	rows, err := k.pool.Query(ctx,
+		`SELECT msg_type, content, headers::text, path, traceparent, correlation_id FROM messages
+			WHERE tag = $1 ORDER BY id ASC`,
+		tag,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var backlog []*message.Message
+
+	for rows.Next() {
+		m := &message.Message{ChannelID: tag, Scope: message.ScopeNotifyOne}
+
+		var headers *string
+
+		if err := rows.Scan(&m.Type, &m.Payload, &headers, &m.Path, &m.TraceParent, &m.CorrelationID); err != nil {
+			return nil, err
+		}
+
+		if m.Headers, err = DecodeHeaders(headers); err != nil {
+			return nil, err
+		}
+
+		backlog = append(backlog, m)
+	}
+
+	return backlog, rows.Err()
+}
@@ -0,0 +1,30 @@
+package storage
+
+import "context"
+
+// BufferedCounts returns the number of buffered messages by channel tag
+func (k *Keeper) BufferedCounts(ctx context.Context) (map[string]int64, error) {
+	rows, err := k.pool.Query(ctx, "SELECT tag, COUNT(*) FROM messages GROUP BY tag")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := map[string]int64{}
+
+	for rows.Next() {
+		var (
+			tag   string
+			count int64
+		)
+
+		if err := rows.Scan(&tag, &count); err != nil {
+			return nil, err
+		}
+
+		counts[tag] = count
+	}
+
+	return counts, rows.Err()
+}
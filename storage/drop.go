@@ -5,17 +5,17 @@ import (
 	"github.com/jackc/pgx/v4"
 )
 
-func (k *Keeper) dropOldest(ctx context.Context, tx pgx.Tx, tag string) error {
+func (k *Keeper) dropOldest(ctx context.Context, tx pgx.Tx, tag string, count int) error {
 	_, err := tx.Exec(ctx,
 		`DELETE FROM messages
 					WHERE tag = $1
-					AND id = (
+					AND id IN (
 						SELECT id FROM messages
 						WHERE tag = $1
 						ORDER BY ID ASC
-						LIMIT 1
+						LIMIT $2
 					)`,
-		tag,
+		tag, count,
 	)
 
 	return err
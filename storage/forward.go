@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"limq/message"
+	"sort"
+	"time"
+)
+
+// ForwardJob is a persisted delivery of a message to the destination of a forwarding rule
+type ForwardJob struct {
+	ID     int64
+	Source string
+	RuleID string
+
+	// Message.ChannelID is the destination
+	Message message.Message
+
+	Attempts int
+}
+
+// EnqueueForwards persists the jobs, they become due immediately
+func (k *Keeper) EnqueueForwards(ctx context.Context, jobs []ForwardJob) error {
+	b := &pgx.Batch{}
+
+	for _, j := range jobs {
+		headers, err := encodeHeaders(j.Message.Headers)
+		if err != nil {
+			return err
+		}
+
+		b.Queue(
+			`INSERT INTO forward_jobs
+				(source, rule_id, destination, msg_type, scope, content, headers, path, traceparent, correlation_id)
+				VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, $8, $9, $10)`,
+			j.Source, j.RuleID, j.Message.ChannelID, j.Message.Type, j.Message.Scope, j.Message.Payload, headers,
+			j.Message.Path, j.Message.TraceParent, j.Message.CorrelationID,
+		)
+	}
+
+	to, cancel := context.WithTimeout(ctx, DBTimeout)
+	defer cancel()
+
+	tx, err := k.pool.Begin(to)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(to)
+
+	if err := tx.SendBatch(to, b).Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit(to)
+}
+
+// ClaimForwards takes up to limit due jobs, hiding them from the other replicas for lease.
+// A job not completed or rescheduled within the lease becomes due again
+func (k *Keeper) ClaimForwards(ctx context.Context, limit int, lease time.Duration) ([]ForwardJob, error) {
+	to, cancel := context.WithTimeout(ctx, DBTimeout)
+	defer cancel()
+
+	rows, err := k.pool.Query(to,
+		`UPDATE forward_jobs SET attempts = attempts + 1, next_attempt = now() + $2::float8 * interval '1 millisecond'
+			WHERE id IN (
+				SELECT id FROM forward_jobs WHERE next_attempt <= now()
+				ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+			) RETURNING id, source, rule_id, destination, msg_type, scope, content, headers::text, path, traceparent,
+				correlation_id, attempts`,
+		limit, lease.Milliseconds(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var jobs []ForwardJob
+
+	for rows.Next() {
+		var (
+			j       ForwardJob
+			headers *string
+		)
+
+		err := rows.Scan(&j.ID, &j.Source, &j.RuleID, &j.Message.ChannelID, &j.Message.Type, &j.Message.Scope,
+			&j.Message.Payload, &headers, &j.Message.Path, &j.Message.TraceParent,
+			&j.Message.CorrelationID, &j.Attempts)
+
+		if err == nil {
+			j.Message.Headers, err = DecodeHeaders(headers)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ID < jobs[j].ID
+	})
+
+	return jobs, nil
+}
+
+// CompleteForward removes a delivered (or abandoned) job
+func (k *Keeper) CompleteForward(ctx context.Context, id int64) error {
+	to, cancel := context.WithTimeout(ctx, DBTimeout)
+	defer cancel()
+
+	_, err := k.pool.Exec(to, `DELETE FROM forward_jobs WHERE id = $1`, id)
+	return err
+}
+
+// RetryForward reschedules a failed job
+func (k *Keeper) RetryForward(ctx context.Context, id int64, after time.Duration, reason string) error {
+	to, cancel := context.WithTimeout(ctx, DBTimeout)
+	defer cancel()
+
+	_, err := k.pool.Exec(to,
+		`UPDATE forward_jobs SET next_attempt = now() + $2::float8 * interval '1 millisecond', last_error = $3 WHERE id = $1`,
+		id, after.Milliseconds(), reason,
+	)
+
+	return err
+}
+
+// PendingForwards counts the undelivered jobs of the source channel by rule ID
+func (k *Keeper) PendingForwards(ctx context.Context, source string) (map[string]int64, error) {
+	to, cancel := context.WithTimeout(ctx, DBTimeout)
+	defer cancel()
+
+	rows, err := k.pool.Query(to, `SELECT rule_id, COUNT(*) FROM forward_jobs WHERE source = $1 GROUP BY rule_id`, source)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	pending := map[string]int64{}
+
+	for rows.Next() {
+		var (
+			rule  string
+			count int64
+		)
+
+		if err := rows.Scan(&rule, &count); err != nil {
+			return nil, err
+		}
+
+		pending[rule] = count
+	}
+
+	return pending, rows.Err()
+}
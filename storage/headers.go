@@ -0,0 +1,32 @@
+package storage
+
+import "encoding/json"
+
+// encodeHeaders returns the value of the headers column, SQL NULL for no headers
+func encodeHeaders(headers map[string]string) (*string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(raw)
+	return &s, nil
+}
+
+// DecodeHeaders parses a headers column value read as text
+func DecodeHeaders(raw *string) (map[string]string, error) {
+	if raw == nil || len(*raw) == 0 {
+		return nil, nil
+	}
+
+	headers := map[string]string{}
+	if err := json.Unmarshal([]byte(*raw), &headers); err != nil {
+		return nil, err
+	}
+
+	return headers, nil
+}
@@ -10,3 +10,8 @@ type Keeper struct {
 func NewKeeper(pool *pgxpool.Pool) *Keeper {
 	return &Keeper{pool: pool}
 }
+
+// Stat describes the connection pool
+func (k *Keeper) Stat() *pgxpool.Stat {
+	return k.pool.Stat()
+}
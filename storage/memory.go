@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"limq/message"
+	"limq/quota"
+	"sync"
+	"time"
+)
+
+// Memory keeps the buffered messages and the forward jobs in the process' memory.
+// It serves a single replica, everything is lost on exit
+type Memory struct {
+	mu       *sync.Mutex
+	messages map[string][]*message.Message
+
+	jobs   []*memoryJob
+	nextID int64
+}
+
+type memoryJob struct {
+	ForwardJob
+	nextAttempt time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		mu:       &sync.Mutex{},
+		messages: map[string][]*message.Message{},
+	}
+}
+
+func (s *Memory) Put(m *message.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buffered := s.messages[m.ChannelID]
+
+	// quota is reached, drop the oldest messages
+	if limit := quota.Current().MaxBufferedMessages; len(buffered) >= limit {
+		buffered = buffered[len(buffered)-limit+1:]
+	}
+
+	stored := *m
+	stored.Scope = message.ScopeNotifyOne
+
+	s.messages[m.ChannelID] = append(buffered, &stored)
+	return nil
+}
+
+func (s *Memory) Take(_ context.Context, tag string) (*message.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buffered := s.messages[tag]
+	if len(buffered) == 0 {
+		return nil, ErrNoMessages
+	}
+
+	m := buffered[0]
+
+	if len(buffered) == 1 {
+		delete(s.messages, tag)
+	} else {
+		s.messages[tag] = buffered[1:]
+	}
+
+	taken := *m
+	return &taken, nil
+}
+
+func (s *Memory) Purge(_ context.Context, tag string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.messages[tag])
+	delete(s.messages, tag)
+
+	return int64(n), nil
+}
+
+func (s *Memory) Backlog(_ context.Context, tag string) ([]*message.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var backlog []*message.Message
+
+	for _, m := range s.messages[tag] {
+		copied := *m
+		backlog = append(backlog, &copied)
+	}
+
+	return backlog, nil
+}
+
+func (s *Memory) BufferedCounts(context.Context) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]int64{}
+
+	for tag, buffered := range s.messages {
+		counts[tag] = int64(len(buffered))
+	}
+
+	return counts, nil
+}
+
+func (s *Memory) EnqueueForwards(_ context.Context, jobs []ForwardJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for _, j := range jobs {
+		s.nextID++
+
+		j.ID = s.nextID
+		j.Attempts = 0
+
+		s.jobs = append(s.jobs, &memoryJob{ForwardJob: j, nextAttempt: now})
+	}
+
+	return nil
+}
+
+func (s *Memory) ClaimForwards(_ context.Context, limit int, lease time.Duration) ([]ForwardJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	var claimed []ForwardJob
+
+	// jobs are kept in the ID order
+	for _, j := range s.jobs {
+		if len(claimed) >= limit {
+			break
+		}
+
+		if j.nextAttempt.After(now) {
+			continue
+		}
+
+		j.Attempts++
+		j.nextAttempt = now.Add(lease)
+
+		claimed = append(claimed, j.ForwardJob)
+	}
+
+	return claimed, nil
+}
+
+func (s *Memory) CompleteForward(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, j := range s.jobs {
+		if j.ID == id {
+			s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *Memory) RetryForward(_ context.Context, id int64, after time.Duration, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.ID == id {
+			j.nextAttempt = time.Now().Add(after)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *Memory) PendingForwards(_ context.Context, source string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := map[string]int64{}
+
+	for _, j := range s.jobs {
+		if j.Source == source {
+			pending[j.RuleID]++
+		}
+	}
+
+	return pending, nil
+}
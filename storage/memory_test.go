@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"limq/message"
+	"limq/quota"
+	"testing"
+	"time"
+)
+
+func TestMemoryMessages(t *testing.T) {
+	q := quota.Current()
+	defer quota.Set(q)
+
+	limited := q
+	limited.MaxBufferedMessages = 2
+	quota.Set(limited)
+
+	s := NewMemory()
+	ctx := context.Background()
+
+	for _, payload := range []string{"1", "2", "3"} {
+		_ = s.Put(&message.Message{ChannelID: "a", Payload: []byte(payload), Scope: message.ScopeNotifyAll})
+	}
+
+	if counts, _ := s.BufferedCounts(ctx); counts["a"] != 2 {
+		t.Fatal("the oldest message must be dropped on quota", counts)
+	}
+
+	m, err := s.Take(ctx, "a")
+	if err != nil || string(m.Payload) != "2" || m.Scope != message.ScopeNotifyOne {
+		t.Fatal("the oldest message must be taken", m, err)
+	}
+
+	if n, _ := s.Purge(ctx, "a"); n != 1 {
+		t.Error("purged", n)
+	}
+
+	if _, err := s.Take(ctx, "a"); !errors.Is(err, ErrNoMessages) {
+		t.Error("purged channel must be empty", err)
+	}
+}
+
+func TestMemoryForwards(t *testing.T) {
+	s := NewMemory()
+	ctx := context.Background()
+
+	_ = s.EnqueueForwards(ctx, []ForwardJob{{Source: "a", RuleID: "r1"}, {Source: "a", RuleID: "r2"}})
+
+	jobs, _ := s.ClaimForwards(ctx, 10, time.Hour)
+	if len(jobs) != 2 || jobs[0].ID >= jobs[1].ID || jobs[0].Attempts != 1 {
+		t.Fatal("claimed", jobs)
+	}
+
+	if again, _ := s.ClaimForwards(ctx, 10, time.Hour); len(again) != 0 {
+		t.Error("leased jobs must not be claimed again", again)
+	}
+
+	_ = s.CompleteForward(ctx, jobs[0].ID)
+	_ = s.RetryForward(ctx, jobs[1].ID, 0, "failed")
+
+	if pending, _ := s.PendingForwards(ctx, "a"); len(pending) != 1 || pending["r2"] != 1 {
+		t.Error("pending", pending)
+	}
+
+	retried, _ := s.ClaimForwards(ctx, 10, time.Hour)
+	if len(retried) != 1 || retried[0].Attempts != 2 {
+		t.Error("retried", retried)
+	}
+}
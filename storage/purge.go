@@ -0,0 +1,13 @@
+package storage
+
+import "context"
+
+// Purge drops every buffered message of the channel and returns their count
+func (k *Keeper) Purge(ctx context.Context, tag string) (int64, error) {
+	result, err := k.pool.Exec(ctx, `DELETE FROM messages WHERE tag = $1`, tag)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -2,14 +2,26 @@ package storage
 
 import (
 	"context"
-	"errors"
 	"github.com/jackc/pgx/v4"
 	"go.uber.org/zap"
 	"limq/message"
+	"limq/metrics"
 	"limq/quota"
+	"limq/tracing"
+	"time"
 )
 
-func (k *Keeper) Put(m *message.Message) error {
+func (k *Keeper) Put(m *message.Message) (err error) {
+	defer metrics.KeeperPut.Since(time.Now())
+
+	span := tracing.Start(m.TraceParent, "limq.store", tracing.KindInternal)
+	span.SetAttribute("limq.channel", m.ChannelID)
+
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	to, cancel := context.WithTimeout(context.Background(), DBTimeout)
 	defer cancel()
 
@@ -48,13 +60,23 @@ func (k *Keeper) Put(m *message.Message) error {
 		return err
 	}
 
+	headers, err := encodeHeaders(m.Headers)
+	if err != nil {
+		return err
+	}
+
 	// insert the message
 	_, err = tx.Exec(
 		context.Background(),
-		"INSERT INTO messages (tag, msg_type, content) VALUES ($1, $2, $3)",
+		`INSERT INTO messages (tag, msg_type, content, headers, path, traceparent, correlation_id)
+			VALUES ($1, $2, $3, $4::jsonb, $5, $6, $7)`,
 		m.ChannelID,
 		m.Type,
 		m.Payload,
+		headers,
+		m.Path,
+		m.TraceParent,
+		m.CorrelationID,
 	)
 
 	if err != nil {
@@ -71,21 +93,19 @@ func (k *Keeper) Put(m *message.Message) error {
 }
 
 func (k *Keeper) dropExcessUnread(ctx context.Context, tx pgx.Tx, m *message.Message, unread int) error {
-	if unread == quota.MaxBufferedMessages {
-		// quota is reached, delete the oldest message
+	limit := quota.Current().MaxBufferedMessages
 
-		err := k.dropOldest(ctx, tx, m.ChannelID)
-		if err != nil {
-			return err
-		}
-	} else if unread > quota.MaxBufferedMessages {
-		// quota is already reached
+	if unread < limit {
+		return nil
+	}
 
-		zap.L().Error("race detected or quota has changed",
-			zap.Int("quota", quota.MaxBufferedMessages), zap.Int("count", unread))
+	if unread > limit {
+		// the quota has been lowered since, the channel is trimmed down to it
 
-		return errors.New("quota is already reached")
+		zap.L().Info("buffered messages exceed the quota",
+			zap.String("chan_id", m.ChannelID), zap.Int("quota", limit), zap.Int("count", unread))
 	}
 
-	return nil
+	// quota is reached, delete the oldest messages
+	return k.dropOldest(ctx, tx, m.ChannelID, unread-limit+1)
 }
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Schema creates the buffered messages table and brings an existing one up to date
+const Schema = `
+CREATE TABLE IF NOT EXISTS messages (
+	id       BIGSERIAL PRIMARY KEY,
+	tag      CHAR(16) NOT NULL,
+	msg_type INTEGER NOT NULL,
+	content  BYTEA NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS messages_tag_id ON messages (tag, id);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS headers JSONB;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS path TEXT[];
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS traceparent TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS correlation_id TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS forward_jobs (
+	id           BIGSERIAL PRIMARY KEY,
+	source       CHAR(16) NOT NULL,
+	rule_id      TEXT NOT NULL,
+	destination  CHAR(16) NOT NULL,
+	msg_type     INTEGER NOT NULL,
+	scope        INTEGER NOT NULL,
+	content      BYTEA NOT NULL,
+	headers      JSONB,
+	path         TEXT[] NOT NULL,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	next_attempt TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_error   TEXT NOT NULL DEFAULT ''
+);
+
+ALTER TABLE forward_jobs ADD COLUMN IF NOT EXISTS traceparent TEXT NOT NULL DEFAULT '';
+ALTER TABLE forward_jobs ADD COLUMN IF NOT EXISTS correlation_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS forward_jobs_next_attempt ON forward_jobs (next_attempt);
+CREATE INDEX IF NOT EXISTS forward_jobs_source ON forward_jobs (source, rule_id);
+`
+
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	_, err := pool.Exec(ctx, Schema)
+	return err
+}
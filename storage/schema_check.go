@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// CheckSchema verifies the tables and columns of Schema exist
+func CheckSchema(ctx context.Context, pool *pgxpool.Pool) error {
+	for _, query := range []string{
+		`SELECT id, tag, msg_type, content, headers, path, traceparent, correlation_id FROM messages LIMIT 0`,
+		`SELECT id, source, rule_id, destination, path, traceparent, correlation_id, attempts, next_attempt FROM forward_jobs LIMIT 0`,
+	} {
+		rows, err := pool.Query(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+	"limq/message"
+)
+
+var ErrNoMessages = errors.New("no buffered messages")
+
+// Take removes the oldest buffered message of the channel and returns it
+func (k *Keeper) Take(ctx context.Context, tag string) (*message.Message, error) {
+	conn, err := k.pool.Acquire(ctx)
+	if err != nil {
+		zap.L().Error("unable to acquire db conn", zap.Error(err), zap.String("tag", tag))
+		return nil, err
+	}
+
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+	if err != nil {
+		zap.L().Error("unable to acquire db tx", zap.Error(err), zap.String("tag", tag))
+		return nil, err
+	}
+
+	row := tx.QueryRow(
+		ctx,
+		`DELETE FROM messages
+			WHERE id = (
+				SELECT id FROM messages WHERE tag = $1 ORDER BY ID ASC LIMIT 1
+			) RETURNING msg_type, content, headers::text, path, traceparent, correlation_id`,
+		tag,
+	)
+
+	nm := &message.Message{ChannelID: tag}
+
+	// manually set scope to one
+	// buffered messages are returned only to the race-winner listener, by design
+	nm.Scope = message.ScopeNotifyOne
+
+	var headers *string
+
+	err = row.Scan(&nm.Type, &nm.Payload, &headers, &nm.Path, &nm.TraceParent, &nm.CorrelationID)
+	if err == nil {
+		nm.Headers, err = DecodeHeaders(headers)
+	}
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			zap.L().Error("unable to rollback db tx", zap.Error(rollbackErr))
+		}
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoMessages
+		}
+
+		zap.L().Error("pgx error", zap.Error(err), zap.String("tag", tag))
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		zap.L().Error("unable to commit db tx", zap.Error(err), zap.String("tag", tag))
+		return nil, err
+	}
+
+	return nm, nil
+}
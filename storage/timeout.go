@@ -2,4 +2,5 @@ package storage
 
 import "time"
 
-const DBTimeout = 1 * time.Second
+// DBTimeout bounds a single storage operation, it's set once on startup
+var DBTimeout = 1 * time.Second
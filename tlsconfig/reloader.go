@@ -0,0 +1,185 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkInterval bounds how often the files are checked for changes, which is done on handshakes
+const checkInterval = 10 * time.Second
+
+// The client certificate modes
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+type Options struct {
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile enables mTLS, the client certificates are verified against its PEM CAs
+	ClientCAFile string
+	// ClientAuth is one of the ClientAuth* modes, ClientAuthOptional if not set while ClientCAFile is
+	ClientAuth string
+}
+
+// Reloader serves the certificate and the client CAs, re-reading them once their files change
+type Reloader struct {
+	opts       Options
+	clientAuth tls.ClientAuthType
+	checkEvery time.Duration
+
+	mu      *sync.Mutex
+	config  *tls.Config
+	modTime time.Time
+	checked time.Time
+}
+
+func New(opts Options) (*Reloader, error) {
+	r := &Reloader{opts: opts, checkEvery: checkInterval, mu: &sync.Mutex{}}
+
+	switch opts.ClientAuth {
+	case ClientAuthNone:
+		r.clientAuth = tls.NoClientCert
+
+	case "", ClientAuthOptional:
+		r.clientAuth = tls.VerifyClientCertIfGiven
+
+	case ClientAuthRequire:
+		r.clientAuth = tls.RequireAndVerifyClientCert
+
+	default:
+		return nil, fmt.Errorf("unknown client auth mode %q", opts.ClientAuth)
+	}
+
+	if len(opts.ClientCAFile) == 0 {
+		if opts.ClientAuth == ClientAuthRequire {
+			return nil, errors.New("client certificates are required, but there are no client CAs")
+		}
+
+		r.clientAuth = tls.NoClientCert
+	}
+
+	modTime, err := r.lastModified()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Config is the server side TLS config
+func (r *Reloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.getConfigForClient,
+	}
+}
+
+func (r *Reloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refresh()
+
+	return r.config, nil
+}
+
+// refresh reloads the files if they have changed since the last load.
+// A failed reload keeps the current config and is retried on the next check
+func (r *Reloader) refresh() {
+	now := time.Now()
+	if now.Sub(r.checked) < r.checkEvery {
+		return
+	}
+
+	r.checked = now
+
+	modTime, err := r.lastModified()
+	if err != nil {
+		zap.L().Warn("unable to check the TLS files", zap.Error(err))
+		return
+	}
+
+	if !modTime.After(r.modTime) {
+		return
+	}
+
+	if err := r.load(modTime); err != nil {
+		zap.L().Warn("unable to reload the TLS files, the current ones are kept", zap.Error(err))
+		return
+	}
+
+	zap.L().Info("TLS files are reloaded", zap.String("cert", r.opts.CertFile))
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+
+	if len(r.opts.ClientCAFile) > 0 {
+		files = append(files, r.opts.ClientCAFile)
+	}
+
+	return files
+}
+
+func (r *Reloader) lastModified() (time.Time, error) {
+	var last time.Time
+
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+
+	return last, nil
+}
+
+func (r *Reloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   r.clientAuth,
+	}
+
+	if len(r.opts.ClientCAFile) > 0 {
+		raw, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(raw) {
+			return fmt.Errorf("%s: no PEM certificates", r.opts.ClientCAFile)
+		}
+
+		config.ClientCAs = pool
+	}
+
+	r.config = config
+	r.modTime = modTime
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type issued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func issue(t *testing.T, serial int64, name string, parent *issued) issued {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	raw, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return issued{cert: cert, key: key}
+}
+
+func (i issued) write(t *testing.T, certFile, keyFile string) {
+	der, err := x509.MarshalECPrivateKey(i.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keyFile) > 0 {
+		if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (i issued) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{i.cert.Raw}, PrivateKey: i.key}
+}
+
+// handshake returns the serial of the server certificate and the client certificate seen by the server
+func handshake(t *testing.T, config *tls.Config, client *tls.Config) (int64, *x509.Certificate, error) {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ln.Close()
+
+	seen := make(chan *x509.Certificate, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			seen <- nil
+			return
+		}
+
+		defer conn.Close()
+
+		tc := conn.(*tls.Conn)
+		if err := tc.Handshake(); err != nil || len(tc.ConnectionState().VerifiedChains) == 0 {
+			seen <- nil
+			return
+		}
+
+		seen <- tc.ConnectionState().VerifiedChains[0][0]
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+	if err != nil {
+		<-seen
+		return 0, nil, err
+	}
+
+	defer conn.Close()
+
+	serial := conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+
+	return serial, <-seen, nil
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+
+	ca := issue(t, 1, "limq-ca", nil)
+	ca.write(t, caFile, "")
+	issue(t, 2, "limq", &ca).write(t, certFile, keyFile)
+
+	r, err := New(Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: ClientAuthRequire})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.checkEvery = 0
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	client := &tls.Config{RootCAs: roots, ServerName: "limq", Certificates: []tls.Certificate{issue(t, 10, "billing", &ca).tls()}}
+
+	serial, peer, err := handshake(t, r.Config(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if serial != 2 || peer == nil || peer.Subject.CommonName != "billing" {
+		t.Fatal("unexpected handshake", serial, peer)
+	}
+
+	issue(t, 3, "limq", &ca).write(t, certFile, keyFile)
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if serial, _, err := handshake(t, r.Config(), client); err != nil || serial != 3 {
+		t.Fatal("the certificate is not reloaded", serial, err)
+	}
+
+	if err := os.WriteFile(keyFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(keyFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if serial, _, err := handshake(t, r.Config(), client); err != nil || serial != 3 {
+		t.Fatal("a broken reload must keep the current certificate", serial, err)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New(Options{CertFile: "missing.pem", KeyFile: "missing.pem"}); err == nil {
+		t.Error("missing files must be rejected")
+	}
+
+	if _, err := New(Options{ClientAuth: "sometimes"}); err == nil {
+		t.Error("unknown client auth mode must be rejected")
+	}
+
+	if _, err := New(Options{ClientAuth: ClientAuthRequire}); err == nil {
+		t.Error("required client certificates without CAs must be rejected")
+	}
+}
@@ -0,0 +1,174 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ServiceName is reported as the service.name resource attribute
+const ServiceName = "limq"
+
+// the OTLP/JSON encoding, see opentelemetry-proto's trace.proto
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         SpanKind        `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	Status       otlpStatus      `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+const (
+	statusOk    = 1
+	statusError = 2
+)
+
+func encodeSpan(s *Span) otlpSpan {
+	o := otlpSpan{
+		TraceID: hex.EncodeToString(s.Context.TraceID[:]),
+		SpanID:  hex.EncodeToString(s.Context.SpanID[:]),
+		Name:    s.Name,
+		Kind:    s.Kind,
+		Start:   strconv.FormatInt(s.Start.UnixNano(), 10),
+		End:     strconv.FormatInt(s.Finish.UnixNano(), 10),
+		Status:  otlpStatus{Code: statusOk},
+	}
+
+	if s.Parent.Valid() {
+		o.ParentSpanID = hex.EncodeToString(s.Parent.SpanID[:])
+	}
+
+	if len(s.ErrorMsg) > 0 {
+		o.Status = otlpStatus{Code: statusError, Message: s.ErrorMsg}
+	}
+
+	keys := make([]string, 0, len(s.Attrs))
+	for k := range s.Attrs {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		o.Attributes = append(o.Attributes, otlpAttribute{Key: k, Value: otlpValue{StringValue: s.Attrs[k]}})
+	}
+
+	return o
+}
+
+func encodeRequest(spans []*Span) otlpRequest {
+	scope := otlpScopeSpans{Spans: make([]otlpSpan, len(spans))}
+	scope.Scope.Name = ServiceName
+
+	for i, s := range spans {
+		scope.Spans[i] = encodeSpan(s)
+	}
+
+	resource := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	resource.Resource.Attributes = []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: ServiceName}}}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{resource}}
+}
+
+// Writer exports the spans to w as OTLP/JSON, one span per line
+type Writer struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{mu: &sync.Mutex{}, w: w}
+}
+
+func (e *Writer) Export(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	enc := json.NewEncoder(e.w)
+
+	for _, s := range spans {
+		if err := enc.Encode(encodeSpan(s)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DefaultOTLPEndpoint is the traces endpoint of a local OpenTelemetry collector
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+// OTLP exports the spans to a collector over OTLP/HTTP with the JSON encoding
+type OTLP struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewOTLP(endpoint string) *OTLP {
+	return &OTLP{endpoint: endpoint, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (e *OTLP) Export(spans []*Span) error {
+	body, err := json.Marshal(encodeRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded with %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"sync"
+	"time"
+)
+
+// SpanKind is the OTLP span kind
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindProducer SpanKind = 4
+	KindConsumer SpanKind = 5
+)
+
+// Span is a timed operation of a trace. A nil *Span is a valid no-op span
+type Span struct {
+	tracer *Tracer
+
+	Name     string
+	Kind     SpanKind
+	Context  SpanContext
+	Parent   SpanContext
+	Start    time.Time
+	Finish   time.Time
+	Attrs    map[string]string
+	ErrorMsg string
+
+	once *sync.Once
+}
+
+// SetAttribute records a key-value pair on the span
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+
+	s.Attrs[key] = value
+}
+
+// SetError marks the span as failed, a nil err is ignored
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.ErrorMsg = err.Error()
+}
+
+// End finishes the span and queues it for export, only the first call counts
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.once.Do(func() {
+		s.Finish = time.Now()
+
+		if s.Context.Sampled {
+			s.tracer.queue(s)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	exportBatch    = 512
+	exportInterval = time.Second
+	exportQueue    = 4096
+)
+
+// Exporter sends finished spans to a backend
+type Exporter interface {
+	Export(spans []*Span) error
+}
+
+// Tracer starts spans and exports them in batches in the background
+type Tracer struct {
+	exporter Exporter
+	spans    chan *Span
+	done     chan struct{}
+
+	mu     *sync.RWMutex
+	closed bool
+}
+
+// NewTracer starts exporting through the exporter; a nil exporter disables tracing,
+// yet the incoming trace context is still propagated
+func NewTracer(exporter Exporter) *Tracer {
+	t := &Tracer{exporter: exporter, mu: &sync.RWMutex{}}
+
+	if exporter != nil {
+		t.spans = make(chan *Span, exportQueue)
+		t.done = make(chan struct{})
+
+		go t.run()
+	}
+
+	return t
+}
+
+var defaultTracer = NewTracer(nil)
+
+// SetDefault replaces the tracer used by Start
+func SetDefault(t *Tracer) {
+	defaultTracer = t
+}
+
+// Start starts a span of the default tracer, see Tracer.Start
+func Start(traceparent string, name string, kind SpanKind) *Span {
+	return defaultTracer.Start(traceparent, name, kind)
+}
+
+// TraceparentOf returns the trace context to propagate past the span;
+// without a tracer it is the (valid) parent's context passed through as is
+func TraceparentOf(s *Span, parent string) string {
+	if s != nil {
+		return s.Context.String()
+	}
+
+	sc, _ := ParseTraceparent(parent)
+	return sc.String()
+}
+
+// Start starts a child span of traceparent, or a root span if it's empty or invalid.
+// It returns nil if tracing is disabled
+func (t *Tracer) Start(traceparent string, name string, kind SpanKind) *Span {
+	if t.exporter == nil {
+		return nil
+	}
+
+	s := &Span{tracer: t, Name: name, Kind: kind, Start: time.Now(), Attrs: map[string]string{}, once: &sync.Once{}}
+
+	if parent, ok := ParseTraceparent(traceparent); ok {
+		s.Parent = parent
+		s.Context.TraceID = parent.TraceID
+		s.Context.Sampled = parent.Sampled
+	} else {
+		randomID(s.Context.TraceID[:])
+		s.Context.Sampled = true
+	}
+
+	randomID(s.Context.SpanID[:])
+
+	return s
+}
+
+func (t *Tracer) queue(s *Span) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return
+	}
+
+	select {
+	case t.spans <- s:
+	default:
+		// the exporter can't keep up, spans are dropped rather than blocking the delivery
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, exportBatch)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := t.exporter.Export(batch); err != nil {
+			zap.L().Warn("unable to export spans", zap.Int("spans", len(batch)), zap.Error(err))
+		}
+
+		batch = make([]*Span, 0, exportBatch)
+	}
+
+	for {
+		select {
+		case s, ok := <-t.spans:
+			if !ok {
+				flush()
+				return
+			}
+
+			batch = append(batch, s)
+			if len(batch) == exportBatch {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Shutdown exports the queued spans; spans ended afterwards are dropped
+func (t *Tracer) Shutdown() {
+	if t.exporter == nil {
+		return
+	}
+
+	t.mu.Lock()
+
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+
+	t.closed = true
+	close(t.spans)
+
+	t.mu.Unlock()
+
+	<-t.done
+}
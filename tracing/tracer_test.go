@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"sync"
+	"testing"
+)
+
+const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+type capture struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (c *capture) Export(spans []*Span) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.spans = append(c.spans, spans...)
+	return nil
+}
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent(parent)
+	if !ok || !sc.Sampled || sc.String() != parent {
+		t.Errorf("unexpected context %+v, %v", sc, ok)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, ok := ParseTraceparent(invalid); ok {
+			t.Errorf("%q is accepted", invalid)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	exporter := &capture{}
+	tracer := NewTracer(exporter)
+
+	span := tracer.Start(parent, "publish", KindProducer)
+	child := tracer.Start(TraceparentOf(span, parent), "deliver", KindConsumer)
+
+	child.End()
+	span.End()
+	span.End()
+
+	tracer.Shutdown()
+
+	if len(exporter.spans) != 2 {
+		t.Fatalf("%d spans are exported", len(exporter.spans))
+	}
+
+	p, _ := ParseTraceparent(parent)
+
+	if span.Context.TraceID != p.TraceID || span.Parent.SpanID != p.SpanID || child.Parent != span.Context {
+		t.Error("trace context is not propagated")
+	}
+
+	if TraceparentOf(nil, parent) != parent || TraceparentOf(nil, "garbage") != "" {
+		t.Error("trace context is not passed through without a tracer")
+	}
+}